@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/flux-agi/flux_go/flux"
+)
+
+// Graph is a graph file of the manager.
+type Graph struct {
+	// TickRateHz is a rate of service.tick broadcast, zero disables ticks.
+	TickRateHz float64        `json:"tick_rate_hz"`
+	Services   []GraphService `json:"services"`
+	Edges      []GraphEdge    `json:"edges"`
+}
+
+type GraphService struct {
+	ID    string      `json:"id"`
+	Nodes []GraphNode `json:"nodes"`
+}
+
+type GraphNode struct {
+	ID       string             `json:"id"`
+	Name     string             `json:"name"`
+	Type     string             `json:"type"`
+	Timer    *flux.TickSettings `json:"timer"`
+	Inputs   []string           `json:"inputs"`
+	Outputs  []string           `json:"outputs"`
+	Settings json.RawMessage    `json:"settings"`
+}
+
+// GraphEdge connects output port of one node with input port of another one, e.g. "camera/frame".
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func loadGraph(path string) (*Graph, error) {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to read graph: %w", err)
+	}
+
+	var graph Graph
+	if err := json.Unmarshal(data, &graph); err != nil {
+		return nil, fmt.Errorf("failed to parse graph: %w", err)
+	}
+
+	return &graph, nil
+}
+
+func splitPort(ref string) (string, string, error) {
+	node, port, ok := strings.Cut(ref, "/")
+	if !ok || node == "" || port == "" {
+		return "", "", fmt.Errorf("invalid port %q, expected <node>/<port>", ref)
+	}
+
+	return node, port, nil
+}
+
+// configs returns node configs of every service, where output ports publish into
+// node/<id>/<port> topics and input ports subscribe to topics of connected outputs.
+func (g *Graph) configs() (map[string]flux.NodesConfig[json.RawMessage], error) {
+	nodes := make(map[string]*GraphNode)
+
+	for i := range g.Services {
+		for j := range g.Services[i].Nodes {
+			node := &g.Services[i].Nodes[j]
+			if _, ok := nodes[node.ID]; ok {
+				return nil, fmt.Errorf("duplicated node %s", node.ID)
+			}
+
+			nodes[node.ID] = node
+		}
+	}
+
+	inputs := make(map[string][]string)
+
+	for _, edge := range g.Edges {
+		fromNode, fromPort, err := splitPort(edge.From)
+		if err != nil {
+			return nil, err
+		}
+
+		toNode, toPort, err := splitPort(edge.To)
+		if err != nil {
+			return nil, err
+		}
+
+		if node, ok := nodes[fromNode]; !ok || !contains(node.Outputs, fromPort) {
+			return nil, fmt.Errorf("edge from unknown output %s", edge.From)
+		}
+
+		if node, ok := nodes[toNode]; !ok || !contains(node.Inputs, toPort) {
+			return nil, fmt.Errorf("edge to unknown input %s", edge.To)
+		}
+
+		inputs[edge.To] = append(inputs[edge.To], flux.NewNodeTopics(fromNode).Port(fromPort))
+	}
+
+	configs := make(map[string]flux.NodesConfig[json.RawMessage], len(g.Services))
+
+	for _, service := range g.Services {
+		config := make(flux.NodesConfig[json.RawMessage], 0, len(service.Nodes))
+
+		for _, node := range service.Nodes {
+			timer := node.Timer
+			if timer == nil {
+				timer = &flux.TickSettings{Type: flux.TimerTypeNone, Interval: 0}
+			}
+
+			cfg := flux.NodeConfig[json.RawMessage]{
+				ID:       node.ID,
+				Inputs:   make([]*flux.Port, 0, len(node.Inputs)),
+				Outputs:  make([]*flux.Port, 0, len(node.Outputs)),
+				Name:     node.Name,
+				Type:     node.Type,
+				Timer:    timer,
+				Settings: node.Settings,
+			}
+
+			for _, port := range node.Inputs {
+				cfg.Inputs = append(cfg.Inputs, &flux.Port{Alias: port, Topics: inputs[node.ID+"/"+port]})
+			}
+
+			for _, port := range node.Outputs {
+				cfg.Outputs = append(cfg.Outputs, &flux.Port{Alias: port, Topics: []string{flux.NewNodeTopics(node.ID).Port(port)}})
+			}
+
+			config = append(config, cfg)
+		}
+
+		configs[service.ID] = config
+	}
+
+	return configs, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+// Command flux-manager is a minimal reference manager for local development and CI.
+//
+// It loads a graph file, answers get_config requests of services, wires port topics
+// between nodes, broadcasts service.tick and collects service statuses.
+// Services and nodes are controlled through HTTP API:
+//
+//	GET  /services                        statuses of services
+//	GET  /services/{id}/registration      node types announced by the service
+//	POST /services/{id}/{start|stop|restart}
+//	POST /services/{id}/config            send config of the service again
+//	POST /services/{id}/registration      ask the service to announce itself again
+//	POST /nodes/{id}/{start|stop}
+//	POST /nodes/{id}/settings             replace node settings with json body
+//
+// With -seed, published messages are signed by the nkey seed, so services with
+// flux.WithMessageSigning accept them, when the public key of the seed is trusted.
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+
+	"github.com/flux-agi/flux_go/flux"
+)
+
+func main() {
+	url := flag.String("nats", cmp.Or(os.Getenv("NATS_URL"), flux.DefaultNatsURL), "NATS url")
+	addr := flag.String("http", ":8686", "address of HTTP API")
+	seed := flag.String("seed", os.Getenv("FLUX_NKEY_SEED"), "file of nkey seed, which signs published messages")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flux-manager [-nats url] [-http addr] [-seed file] <graph.json>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	if err := run(*url, *addr, *seed, flag.Arg(0), logger); err != nil {
+		logger.Error("manager failed", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func run(url, addr, seedPath, graphPath string, logger *slog.Logger) error {
+	graph, err := loadGraph(graphPath)
+	if err != nil {
+		return err
+	}
+
+	var signer nkeys.KeyPair
+	if seedPath != "" {
+		signer, err = loadSigner(seedPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	configs, err := graph.configs()
+	if err != nil {
+		return fmt.Errorf("invalid graph: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	defer conn.Close()
+
+	m := newManager(conn, signer, configs, logger)
+
+	if err := m.subscribe(); err != nil {
+		return err
+	}
+
+	for service := range configs {
+		// services, which were started before the manager, get config without asking
+		if err := m.sendConfig(service); err != nil {
+			return err
+		}
+
+		if err := m.publish(flux.NewTopics(service).RequestRegistration(), nil); err != nil {
+			return err
+		}
+	}
+
+	if graph.TickRateHz > 0 {
+		go m.tick(ctx, graph.TickRateHz)
+	}
+
+	logger.Info("manager started", slog.String("http", addr), slog.Int("services", len(configs)))
+
+	return m.serve(ctx, addr)
+}
+
+func loadSigner(path string) (nkeys.KeyPair, error) {
+	seed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed: %w", err)
+	}
+
+	signer, err := nkeys.FromSeed(bytes.TrimSpace(seed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid seed: %w", err)
+	}
+
+	return signer, nil
+}
+
+func marshalConfig(config flux.NodesConfig[json.RawMessage]) ([]byte, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	return data, nil
+}
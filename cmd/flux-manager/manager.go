@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+
+	wants "github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
+
+	"github.com/flux-agi/flux_go/flux"
+	"github.com/flux-agi/flux_go/fluxmq"
+)
+
+type serviceStatus struct {
+	Status    flux.ServiceStatus `json:"status"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+type manager struct {
+	conn      *nats.Conn
+	marshaler *wants.NATSMarshaler
+	// signer signs published messages, nil when signing is disabled
+	signer  nkeys.KeyPair
+	configs map[string]flux.NodesConfig[json.RawMessage]
+	logger  *slog.Logger
+
+	mu            sync.RWMutex
+	statuses      map[string]serviceStatus
+	registrations map[string]json.RawMessage
+}
+
+func newManager(
+	conn *nats.Conn,
+	signer nkeys.KeyPair,
+	configs map[string]flux.NodesConfig[json.RawMessage],
+	logger *slog.Logger,
+) *manager {
+	return &manager{
+		conn:          conn,
+		marshaler:     new(wants.NATSMarshaler),
+		signer:        signer,
+		configs:       configs,
+		logger:        logger,
+		mu:            sync.RWMutex{},
+		statuses:      make(map[string]serviceStatus),
+		registrations: make(map[string]json.RawMessage),
+	}
+}
+
+// publish publishes payload in the same format as the SDK publisher.
+func (m *manager) publish(topic string, payload []byte) error {
+	watermillMsg := message.NewMessage(watermill.NewUUID(), payload)
+
+	if m.signer != nil {
+		if err := fluxmq.Sign(m.signer, topic, watermillMsg); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	msg, err := m.marshaler.Marshal(topic, watermillMsg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if err := m.conn.PublishMsg(msg); err != nil {
+		return fmt.Errorf("failed to publish into %s: %w", topic, err)
+	}
+
+	return nil
+}
+
+// serviceFromTopic returns service id of service.<id>.<name> topic.
+func serviceFromTopic(topic string) string {
+	parts := strings.Split(topic, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+
+	return strings.Join(parts[1:len(parts)-1], ".")
+}
+
+func (m *manager) subscribe() error {
+	// subjects are built with ServiceTopics, "*" stands for service id
+	topics := flux.NewTopics("*")
+
+	if _, err := m.conn.Subscribe(topics.RequestConfig(), func(msg *nats.Msg) {
+		service := serviceFromTopic(msg.Subject)
+		if err := m.sendConfig(service); err != nil {
+			m.logger.Error("failed to send config", slog.String("service", service), slog.String("err", err.Error()))
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to config requests: %w", err)
+	}
+
+	if _, err := m.conn.Subscribe(topics.SendStatus(), func(msg *nats.Msg) {
+		service := serviceFromTopic(msg.Subject)
+		status := flux.ServiceStatus(msg.Data)
+
+		m.mu.Lock()
+		m.statuses[service] = serviceStatus{Status: status, UpdatedAt: time.Now()}
+		m.mu.Unlock()
+
+		m.logger.Info("service status", slog.String("service", service), slog.String("status", string(status)))
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to statuses: %w", err)
+	}
+
+	if _, err := m.conn.Subscribe(topics.Registration(), func(msg *nats.Msg) {
+		service := serviceFromTopic(msg.Subject)
+
+		m.mu.Lock()
+		m.registrations[service] = json.RawMessage(msg.Data)
+		m.mu.Unlock()
+
+		m.logger.Info("service registered", slog.String("service", service))
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to registrations: %w", err)
+	}
+
+	return nil
+}
+
+func (m *manager) sendConfig(service string) error {
+	config, ok := m.configs[service]
+	if !ok {
+		m.logger.Warn("config of unknown service is requested", slog.String("service", service))
+		config = flux.NodesConfig[json.RawMessage]{}
+	}
+
+	data, err := marshalConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return m.publish(flux.NewTopics(service).ResponseConfig(), data)
+}
+
+func (m *manager) tick(ctx context.Context, rate float64) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			data, _ := json.Marshal(map[string]int64{"timestamp": now.UnixMilli()})
+			if err := m.publish(flux.TopicGlobalTick, data); err != nil {
+				m.logger.Error("failed to publish tick", slog.String("err", err.Error()))
+			}
+		}
+	}
+}
+
+func (m *manager) serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /services", m.handleStatuses)
+	mux.HandleFunc("GET /services/{id}/registration", m.handleRegistration)
+	mux.HandleFunc("POST /services/{id}/{command}", m.handleServiceCommand)
+	mux.HandleFunc("POST /nodes/{id}/{command}", m.handleNodeCommand)
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second} //nolint:exhaustruct
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx) //nolint:contextcheck
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve http: %w", err)
+	}
+
+	return nil
+}
+
+func (m *manager) handleStatuses(w http.ResponseWriter, _ *http.Request) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make(map[string]serviceStatus, len(m.configs))
+	for service := range m.configs {
+		statuses[service] = serviceStatus{Status: flux.ServiceStatusStarting, UpdatedAt: time.Time{}}
+	}
+
+	for service, status := range m.statuses {
+		statuses[service] = status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+func (m *manager) handleRegistration(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	registration, ok := m.registrations[r.PathValue("id")]
+	m.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, "service is not registered", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(registration)
+}
+
+func (m *manager) handleServiceCommand(w http.ResponseWriter, r *http.Request) {
+	service, topics := r.PathValue("id"), flux.NewTopics(r.PathValue("id"))
+
+	var err error
+
+	switch r.PathValue("command") {
+	case "start":
+		err = m.publish(topics.Start(), nil)
+	case "stop":
+		err = m.publish(topics.Stop(), nil)
+	case "restart":
+		err = m.publish(topics.Restart(), nil)
+	case "config":
+		err = m.sendConfig(service)
+	case "registration":
+		err = m.publish(topics.RequestRegistration(), nil)
+	default:
+		http.Error(w, "unknown command", http.StatusNotFound)
+		return
+	}
+
+	m.reply(w, err)
+}
+
+func (m *manager) handleNodeCommand(w http.ResponseWriter, r *http.Request) {
+	topics := flux.NewNodeTopics(r.PathValue("id"))
+
+	var err error
+
+	switch command := r.PathValue("command"); command {
+	case "start", "stop":
+		err = m.publish(topics.Event(command), nil)
+	case "settings":
+		var settings json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(w, "invalid settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = m.publish(topics.Settings(), settings)
+	default:
+		http.Error(w, "unknown command", http.StatusNotFound)
+		return
+	}
+
+	m.reply(w, err)
+}
+
+func (m *manager) reply(w http.ResponseWriter, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+
+	wants "github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
+
+	"github.com/flux-agi/flux_go/flux"
+	"github.com/flux-agi/flux_go/fluxmq"
+)
+
+var (
+	marshaler = new(wants.NATSMarshaler)
+	// signer signs published messages, when it is loaded
+	signer nkeys.KeyPair
+)
+
+func loadSigner(path string) error {
+	seed, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read seed: %w", err)
+	}
+
+	signer, err = nkeys.FromSeed(bytes.TrimSpace(seed))
+	if err != nil {
+		return fmt.Errorf("invalid seed: %w", err)
+	}
+
+	return nil
+}
+
+// publish publishes payload in the same format as the SDK publisher.
+func publish(conn *nats.Conn, topic string, payload []byte) error {
+	watermillMsg := message.NewMessage(watermill.NewUUID(), payload)
+
+	if signer != nil {
+		if err := fluxmq.Sign(signer, topic, watermillMsg); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	msg, err := marshaler.Marshal(topic, watermillMsg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if err := conn.PublishMsg(msg); err != nil {
+		return fmt.Errorf("failed to publish into %s: %w", topic, err)
+	}
+
+	return conn.Flush() //nolint:wrapcheck
+}
+
+// serviceFromTopic returns service id of service.<id>.<name> topic.
+func serviceFromTopic(topic string) string {
+	parts := strings.Split(topic, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+
+	return strings.Join(parts[1:len(parts)-1], ".")
+}
+
+// runServices lists services, which published anything within -wait.
+//
+// NATS has no way to enumerate subscribers, so services are discovered by their statuses, metrics and
+// registrations. Every discovered service, and every service named in args, is asked for its status
+// and registration, so services with disabled metrics answer as well. Services, which are not running
+// or not connected, are not listed.
+func runServices(ctx context.Context, conn *nats.Conn, args []string) error {
+	flags := flag.NewFlagSet("services", flag.ContinueOnError)
+	wait := flags.Duration("wait", 5*time.Second, "time of listening to services")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	type service struct {
+		status   string
+		lastSeen time.Time
+	}
+
+	services := make(map[string]*service)
+	messages := make(chan *nats.Msg, 64)
+
+	// statuses are sent on change, metrics periodically and registrations on start and request,
+	// all of them prove that service is alive
+	for _, subject := range []string{"service.*.status", "service.*.metrics", "service.*.registration"} {
+		sub, err := conn.ChanSubscribe(subject, messages)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe: %w", err)
+		}
+		defer sub.Unsubscribe() //nolint:errcheck
+	}
+
+	probed := make(map[string]bool)
+	probe := func(id string) {
+		if probed[id] {
+			return
+		}
+		probed[id] = true
+
+		topics := flux.NewTopics(id)
+		for _, topic := range []string{topics.RequestStatus(), topics.RequestRegistration()} {
+			if err := publish(conn, topic, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to probe service %s: %v\n", id, err)
+			}
+		}
+	}
+
+	for _, id := range flags.Args() {
+		probe(id)
+	}
+
+	timer := time.NewTimer(*wait)
+	defer timer.Stop()
+
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-timer.C:
+			done = true
+		case msg := <-messages:
+			id := serviceFromTopic(msg.Subject)
+			if services[id] == nil {
+				services[id] = &service{status: "", lastSeen: time.Time{}}
+			}
+
+			services[id].lastSeen = time.Now()
+			if strings.HasSuffix(msg.Subject, ".status") {
+				services[id].status = string(msg.Data)
+			}
+
+			// status is sent only on change, so it is requested from every discovered service
+			probe(id)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tSTATUS\tLAST SEEN")
+
+	for _, id := range slices.Sorted(maps.Keys(services)) {
+		status := cmp.Or(services[id].status, "-")
+		fmt.Fprintf(w, "%s\t%s\t%s\n", id, status, services[id].lastSeen.Format(time.TimeOnly))
+	}
+
+	return w.Flush() //nolint:wrapcheck
+}
+
+func runStatus(ctx context.Context, conn *nats.Conn, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	timeout := flags.Duration("timeout", 3*time.Second, "time of waiting for the status")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	topics := flux.NewTopics(flags.Arg(0))
+
+	sub, err := conn.SubscribeSync(topics.SendStatus())
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	defer sub.Unsubscribe() //nolint:errcheck
+
+	if err := publish(conn, topics.RequestStatus(), nil); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	msg, err := sub.NextMsgWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("service %s did not answer: %w", flags.Arg(0), err)
+	}
+
+	fmt.Println(string(msg.Data))
+
+	return nil
+}
+
+// runEcho prints messages of the node output port.
+//
+// Nodes publish into topics of their output ports from config, which only the manager knows,
+// so topics are resolved from the config file of the service or given with -topic.
+// Legacy node/<id>/<port> topic is used by services with legacy port topics only.
+func runEcho(ctx context.Context, conn *nats.Conn, args []string) error {
+	flags := flag.NewFlagSet("echo", flag.ContinueOnError)
+	topic := flags.String("topic", "", "topic of the port, messages of other nodes in it are skipped")
+	configPath := flags.String("config", "", "config file of the service, which topics of the port are read from")
+	legacy := flags.Bool("legacy", false, "use legacy node/<id>/<port> topic")
+	raw := flags.Bool("raw", false, "print payload without decoding")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+
+	node, port := flags.Arg(0), flags.Arg(1)
+
+	var subjects []string
+
+	switch {
+	case *topic != "":
+		subjects = []string{*topic}
+	case *configPath != "":
+		topics, err := outputTopics(*configPath, node, port)
+		if err != nil {
+			return err
+		}
+
+		subjects = topics
+	case *legacy:
+		subjects = []string{flux.NewNodeTopics(node).Port(port)}
+	default:
+		return errors.New("topics of the port are unknown: pass -config of the service or -topic, " +
+			"-legacy for services with legacy port topics")
+	}
+
+	messages := make(chan *nats.Msg, 64)
+
+	for _, subject := range subjects {
+		sub, err := conn.ChanSubscribe(subject, messages)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe: %w", err)
+		}
+		defer sub.Unsubscribe() //nolint:errcheck
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case natsMsg := <-messages:
+			msg, err := marshaler.Unmarshal(natsMsg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to unmarshal message: %v\n", err)
+				continue
+			}
+
+			// topic may be shared by several nodes
+			envelope := flux.EnvelopeFromMessage(msg)
+			if envelope.SourceNode != "" && (envelope.SourceNode != node || envelope.Port != port) {
+				continue
+			}
+
+			printMessage(natsMsg.Subject, msg, *raw)
+		}
+	}
+}
+
+// outputTopics returns topics of the node output port from the service config file.
+func outputTopics(path, node, port string) ([]string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config flux.NodesConfig[json.RawMessage]
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	for _, n := range config {
+		if n.ID != node {
+			continue
+		}
+
+		var topics []string
+		for _, p := range n.Outputs {
+			if p != nil && p.Alias == port {
+				topics = append(topics, p.Topics...)
+			}
+		}
+
+		if len(topics) == 0 {
+			return nil, fmt.Errorf("output %q of node %s has no topics in %s", port, node, path)
+		}
+
+		return topics, nil
+	}
+
+	return nil, fmt.Errorf("node %s is not found in %s", node, path)
+}
+
+func printMessage(subject string, msg *message.Message, raw bool) {
+	envelope := flux.EnvelopeFromMessage(msg)
+
+	fmt.Printf("--- %s %s", subject, time.Now().Format(time.TimeOnly))
+	if envelope.SourceNode != "" {
+		fmt.Printf(" from %s/%s seq=%d", envelope.SourceNode, envelope.Port, envelope.Sequence)
+	}
+	fmt.Println()
+
+	// chunks are reassembled and payloads are decrypted by the SDK subscriber only
+	if transfer := msg.Metadata.Get(fluxmq.MetadataChunkTransfer); transfer != "" {
+		fmt.Printf(
+			"warning: chunk %s of %s of transfer %s, chunks are not reassembled\n",
+			msg.Metadata.Get(fluxmq.MetadataChunkIndex), msg.Metadata.Get(fluxmq.MetadataChunkCount), transfer,
+		)
+		raw = true
+	}
+
+	if encryption := msg.Metadata.Get(flux.MetadataEncryption); encryption != "" {
+		fmt.Printf("warning: payload is encrypted with %s, it is not decrypted\n", encryption)
+		raw = true
+	}
+
+	if !raw {
+		if err := flux.DecodePayload(msg); err != nil {
+			fmt.Printf("could not decode payload: %v\n", err)
+			return
+		}
+	}
+
+	var pretty bytes.Buffer
+	if !raw && json.Indent(&pretty, msg.Payload, "", "  ") == nil {
+		fmt.Println(pretty.String())
+		return
+	}
+
+	fmt.Printf("%q\n", msg.Payload)
+}
+
+func runRate(ctx context.Context, conn *nats.Conn, args []string) error {
+	flags := flag.NewFlagSet("rate", flag.ContinueOnError)
+	interval := flags.Duration("interval", time.Second, "interval of printing rates")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+
+	messages := make(chan *nats.Msg, 1024)
+
+	for _, subject := range flags.Args() {
+		sub, err := conn.ChanSubscribe(subject, messages)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe: %w", err)
+		}
+		defer sub.Unsubscribe() //nolint:errcheck
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	counts := make(map[string]int)
+	sizes := make(map[string]int)
+	last := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case msg := <-messages:
+			counts[msg.Subject]++
+			sizes[msg.Subject] += len(msg.Data)
+
+		case now := <-ticker.C:
+			seconds := now.Sub(last).Seconds()
+			last = now
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "TOPIC\tMSG/S\tBYTES/S\t%s\n", now.Format(time.TimeOnly))
+
+			for _, subject := range slices.Sorted(maps.Keys(counts)) {
+				fmt.Fprintf(w, "%s\t%.1f\t%.0f\t\n", subject, float64(counts[subject])/seconds, float64(sizes[subject])/seconds)
+			}
+
+			_ = w.Flush()
+
+			clear(counts)
+			clear(sizes)
+		}
+	}
+}
+
+func runPushConfig(_ context.Context, conn *nats.Conn, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	data, err := os.ReadFile(args[1])
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if !json.Valid(data) {
+		return fmt.Errorf("config %s is not a valid json", args[1])
+	}
+
+	return publish(conn, flux.NewTopics(args[0]).ResponseConfig(), data)
+}
+
+func runNode(_ context.Context, conn *nats.Conn, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	event, topics := args[0], flux.NewNodeTopics(args[1])
+
+	switch event {
+	case "start", "stop":
+		return publish(conn, topics.Event(event), nil)
+
+	case "restart":
+		if err := publish(conn, topics.Event("stop"), nil); err != nil {
+			return err
+		}
+
+		return publish(conn, topics.Event("start"), nil)
+
+	case "settings":
+		if len(args) != 3 {
+			return errUsage
+		}
+
+		data, err := os.ReadFile(args[2])
+		if err != nil {
+			return fmt.Errorf("failed to read settings: %w", err)
+		}
+
+		if !json.Valid(data) {
+			return fmt.Errorf("settings %s is not a valid json", args[2])
+		}
+
+		return publish(conn, topics.Settings(), data)
+
+	default:
+		return errUsage
+	}
+}
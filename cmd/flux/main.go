@@ -0,0 +1,97 @@
+// Command flux inspects and drives services built on the flux SDK over NATS.
+//
+// Usage:
+//
+//	flux [-nats url] [-seed file] <command> [flags] [args]
+//
+// With -seed, published messages are signed by the nkey seed, see flux.WithMessageSigning.
+//
+// Commands:
+//
+//	services [service]...            list live services and their statuses
+//	status <service>                 query service status through request_status
+//	echo <node> <port>               print messages of the node output port, topics are read from -config
+//	                                 or given with -topic, chunks and encrypted payloads are not decoded
+//	rate <topic>...                  measure message rate per topic, NATS wildcards are allowed
+//	push-config <service> <file>     send config file to the service
+//	node <event> <node> [file]       send start, stop, restart or settings event to the node
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/flux-agi/flux_go/flux"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, conn *nats.Conn, args []string) error
+}
+
+var commands = map[string]command{
+	"services":    {usage: "services [-wait 5s] [service]...", run: runServices},
+	"status":      {usage: "status [-timeout 3s] <service>", run: runStatus},
+	"echo":        {usage: "echo <-config file | -topic topic | -legacy> [-raw] <node> <port>", run: runEcho},
+	"rate":        {usage: "rate [-interval 1s] <topic>...", run: runRate},
+	"push-config": {usage: "push-config <service> <file>", run: runPushConfig},
+	"node":        {usage: "node <start|stop|restart|settings> <node> [settings file]", run: runNode},
+}
+
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	url := flag.String("nats", cmp.Or(os.Getenv("NATS_URL"), flux.DefaultNatsURL), "NATS url")
+	seed := flag.String("seed", os.Getenv("FLUX_NKEY_SEED"), "file of nkey seed, which signs published messages")
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if *seed != "" {
+		if err := loadSigner(*seed); err != nil {
+			fmt.Fprintf(os.Stderr, "flux: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	conn, err := nats.Connect(*url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "flux: failed to connect to nats: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	err = cmd.run(ctx, conn, flag.Args()[1:])
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(os.Stderr, "usage: flux %s\n", cmd.usage)
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "flux: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: flux [-nats url] [-seed file] <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+
+	for _, name := range []string{"services", "status", "echo", "rate", "push-config", "node"} {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
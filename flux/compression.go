@@ -0,0 +1,275 @@
+package flux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// MetadataEncoding is a metadata key of the payload compression algorithm.
+const MetadataEncoding = "flux_encoding"
+
+// DefaultCompressionThreshold is a payload size, below which messages are sent uncompressed.
+const DefaultCompressionThreshold = 1024
+
+// DefaultMaxDecodedSize is a maximal size of decompressed payload, see WithMaxDecodedSize.
+const DefaultMaxDecodedSize = 64 * 1024 * 1024
+
+var (
+	ErrUnknownEncoding = errors.New("flux: unknown payload encoding")
+	ErrPayloadTooLarge = errors.New("flux: decompressed payload is too large")
+)
+
+type CompressionAlgorithm string
+
+const (
+	CompressionNone CompressionAlgorithm = ""
+	CompressionZstd CompressionAlgorithm = "zstd"
+	CompressionS2   CompressionAlgorithm = "s2"
+	CompressionGzip CompressionAlgorithm = "gzip"
+)
+
+// CompressionSettings is a payload compression settings of the output port.
+type CompressionSettings struct {
+	Algorithm CompressionAlgorithm `json:"algorithm"`
+	// Threshold is a payload size in bytes, below which messages are sent uncompressed.
+	// Zero means DefaultCompressionThreshold.
+	Threshold int `json:"threshold"`
+}
+
+func (s CompressionSettings) threshold() int {
+	if s.Threshold <= 0 {
+		return DefaultCompressionThreshold
+	}
+
+	return s.Threshold
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	// zstdDecoders are shared decoders by maximal decoded size.
+	zstdDecoders sync.Map
+)
+
+// sharedZstdEncoder returns shared encoder, it is safe for concurrent EncodeAll.
+func sharedZstdEncoder() *zstd.Encoder {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil)
+	})
+
+	return zstdEncoder
+}
+
+// sharedZstdDecoder returns shared decoder with the limit of decoded size, it is safe for concurrent DecodeAll.
+func sharedZstdDecoder(limit int) (*zstd.Decoder, error) {
+	if decoder, ok := zstdDecoders.Load(limit); ok {
+		return decoder.(*zstd.Decoder), nil //nolint:forcetypeassert
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("could not create zstd decoder: %w", err)
+	}
+
+	actual, loaded := zstdDecoders.LoadOrStore(limit, decoder)
+	if loaded {
+		decoder.Close()
+	}
+
+	return actual.(*zstd.Decoder), nil //nolint:forcetypeassert
+}
+
+func compress(algorithm CompressionAlgorithm, payload []byte) ([]byte, error) {
+	switch algorithm {
+	case CompressionNone:
+		return payload, nil
+
+	case CompressionZstd:
+		return sharedZstdEncoder().EncodeAll(payload, make([]byte, 0, len(payload)/2)), nil
+
+	case CompressionS2:
+		return s2.Encode(nil, payload), nil
+
+	case CompressionGzip:
+		var buf bytes.Buffer
+
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(payload); err != nil {
+			return nil, fmt.Errorf("could not compress payload: %w", err)
+		}
+
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("could not compress payload: %w", err)
+		}
+
+		return buf.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, algorithm)
+	}
+}
+
+// decompress decodes payload, it returns ErrPayloadTooLarge, when decoded payload exceeds limit.
+func decompress(algorithm CompressionAlgorithm, payload []byte, limit int) ([]byte, error) {
+	switch algorithm {
+	case CompressionNone:
+		return payload, nil
+
+	case CompressionZstd:
+		decoder, err := sharedZstdDecoder(limit)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := decoder.DecodeAll(payload, nil)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			return nil, fmt.Errorf("%w: limit %d", ErrPayloadTooLarge, limit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress payload: %w", err)
+		}
+
+		return data, nil
+
+	case CompressionS2:
+		size, err := s2.DecodedLen(payload)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress payload: %w", err)
+		}
+
+		if size > limit {
+			return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrPayloadTooLarge, size, limit)
+		}
+
+		data, err := s2.Decode(nil, payload)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress payload: %w", err)
+		}
+
+		return data, nil
+
+	case CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress payload: %w", err)
+		}
+		defer reader.Close()
+
+		// one byte over the limit tells too large payload from payload of exactly limit size
+		data, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress payload: %w", err)
+		}
+
+		if len(data) > limit {
+			return nil, fmt.Errorf("%w: limit %d", ErrPayloadTooLarge, limit)
+		}
+
+		return data, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, algorithm)
+	}
+}
+
+// SetOutputCompression sets default payload compression of the output port.
+// Compression from the port config takes precedence over it.
+func (n *NodeHandlers[T]) SetOutputCompression(port string, settings CompressionSettings) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.outputCompression == nil {
+		n.outputCompression = make(map[string]CompressionSettings)
+	}
+	n.outputCompression[port] = settings
+}
+
+// initCompression sets compression of output ports from handlers and port config.
+func (n *Node[T]) initCompression(defaults map[string]CompressionSettings) {
+	for port, settings := range defaults {
+		n.compression[port] = settings
+	}
+
+	for _, p := range n.config.Outputs {
+		if p.Compression != nil {
+			n.compression[p.Alias] = *p.Compression
+		}
+	}
+}
+
+// encodePayload compresses payload of the output port, when it is not smaller than threshold.
+// Algorithm is recorded into message metadata.
+func (n *Node[T]) encodePayload(port string, msg *message.Message) error {
+	settings, ok := n.compression[port]
+	if !ok || settings.Algorithm == CompressionNone || len(msg.Payload) < settings.threshold() {
+		return nil
+	}
+
+	payload, err := compress(settings.Algorithm, msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	msg.Payload = payload
+	msg.Metadata.Set(MetadataEncoding, string(settings.Algorithm))
+
+	return nil
+}
+
+// DecodePayload decompresses payload of the received message according to its metadata.
+// Payloads larger than DefaultMaxDecodedSize are rejected with ErrPayloadTooLarge.
+func DecodePayload(msg *message.Message) error {
+	return DecodePayloadLimit(msg, DefaultMaxDecodedSize)
+}
+
+// DecodePayloadLimit decompresses payload of the received message according to its metadata.
+// Payloads larger than limit bytes are rejected with ErrPayloadTooLarge.
+func DecodePayloadLimit(msg *message.Message, limit int) error {
+	algorithm := CompressionAlgorithm(msg.Metadata.Get(MetadataEncoding))
+	if algorithm == CompressionNone {
+		return nil
+	}
+
+	payload, err := decompress(algorithm, msg.Payload, limit)
+	if err != nil {
+		return err
+	}
+
+	msg.Payload = payload
+	delete(msg.Metadata, MetadataEncoding)
+
+	return nil
+}
+
+// decodePayload decrypts and decompresses payload of the input port message.
+// Encrypted original of the message is kept in its context, see keepEncryptedOriginal.
+func (n *Node[T]) decodePayload(port string, msg *message.Message) error {
+	keepEncryptedOriginal(msg)
+
+	if err := n.decryptPayload(port, msg); err != nil {
+		return err
+	}
+
+	return DecodePayloadLimit(msg, n.maxDecodedSize)
+}
+
+// decodeReceived decodes payload of the input port message.
+// Message, which cannot be decoded, is logged and acknowledged, because redelivery would not help.
+func (n *Node[T]) decodeReceived(port string, msg *message.Message) bool {
+	if err := n.decodePayload(port, msg); err != nil {
+		n.logger.Error("could not decode received message", slog.String("port", port), slog.Any("err", err))
+		msg.Ack()
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,66 @@
+package flux
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestDecodePayloadLimit(t *testing.T) {
+	t.Parallel()
+
+	payload := bytes.Repeat([]byte("flux payload "), 1000)
+
+	tests := []struct {
+		name      string
+		algorithm CompressionAlgorithm
+		limit     int
+		wantErr   error
+	}{
+		{name: "zstd", algorithm: CompressionZstd, limit: len(payload), wantErr: nil},
+		{name: "s2", algorithm: CompressionS2, limit: len(payload), wantErr: nil},
+		{name: "gzip", algorithm: CompressionGzip, limit: len(payload), wantErr: nil},
+		{name: "zstd over limit", algorithm: CompressionZstd, limit: len(payload) / 2, wantErr: ErrPayloadTooLarge},
+		{name: "s2 over limit", algorithm: CompressionS2, limit: len(payload) - 1, wantErr: ErrPayloadTooLarge},
+		{name: "gzip over limit", algorithm: CompressionGzip, limit: len(payload) - 1, wantErr: ErrPayloadTooLarge},
+		{name: "unknown", algorithm: "lz4", limit: len(payload), wantErr: ErrUnknownEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			msg := message.NewMessage("id", payload)
+			msg.Metadata.Set(MetadataEncoding, string(tt.algorithm))
+
+			if tt.algorithm != "lz4" {
+				compressed, err := compress(tt.algorithm, payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(compressed) >= len(payload) {
+					t.Fatalf("payload is not compressed: %d bytes", len(compressed))
+				}
+				msg.Payload = compressed
+			}
+
+			err := DecodePayloadLimit(msg, tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !bytes.Equal(msg.Payload, payload) {
+				t.Fatal("decoded payload does not match the original")
+			}
+			if msg.Metadata.Get(MetadataEncoding) != "" {
+				t.Fatal("encoding metadata is left in decoded message")
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 const (
 	DefaultNatsURL              = "nats://localhost:4222"
 	DefaultConfigWaitingTimeout = 5 * time.Second
+	DefaultMetricsInterval      = 10 * time.Second
 )
 
 type RunOptions struct {
@@ -21,6 +22,10 @@ type RunOptions struct {
 	callFactory     CallerFactory
 	routerFactory   RouterFactory
 	configTimeout   time.Duration
+	httpAddr        string
+	metricsInterval time.Duration
+	chunking        *fluxmq.ChunkConfig
+	signing         *SigningOptions
 }
 
 type ConnectOption func(*RunOptions)
@@ -91,3 +96,37 @@ func WithConfigTimeout(configTimeout time.Duration) ConnectOption {
 		n.configTimeout = configTimeout
 	}
 }
+
+// WithHTTPAddr enables HTTP server of the service, which serves /metrics in Prometheus format,
+// /healthz and /readyz probes. By default, it is enabled by FLUX_HTTP_ADDR env, e.g. ":9090".
+func WithHTTPAddr(addr string) ConnectOption {
+	return func(n *RunOptions) {
+		n.httpAddr = addr
+	}
+}
+
+// WithMetricsInterval sets interval of publishing metrics into service.<id>.metrics topic.
+// Zero interval disables publishing.
+func WithMetricsInterval(interval time.Duration) ConnectOption {
+	return func(n *RunOptions) {
+		n.metricsInterval = interval
+	}
+}
+
+// WithChunking enables chunked transfer: payloads larger than chunk size are split into
+// sequenced chunks by publisher and reassembled by subscriber before handlers run.
+// All services exchanging large payloads should enable it.
+func WithChunking(config fluxmq.ChunkConfig) ConnectOption {
+	return func(n *RunOptions) {
+		n.chunking = &config
+	}
+}
+
+// WithMessageSigning signs published messages with ed25519 key and drops received ones,
+// which are not signed by the service itself or trusted keys, before they reach handlers.
+// Control topics are always covered, node ports only with SignData.
+func WithMessageSigning(options SigningOptions) ConnectOption {
+	return func(n *RunOptions) {
+		n.signing = &options
+	}
+}
@@ -0,0 +1,220 @@
+package flux
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Metadata keys of dead letters, published into service.<id>.dlq topic
+// with the original payload and metadata. Messages of encrypted ports keep their encrypted payload.
+const (
+	MetadataDeadLetterError    = "flux_dlq_error"
+	MetadataDeadLetterNode     = "flux_dlq_node"
+	MetadataDeadLetterPort     = "flux_dlq_port"
+	MetadataDeadLetterTopic    = "flux_dlq_topic"
+	MetadataDeadLetterFailedAt = "flux_dlq_failed_at"
+)
+
+var ErrDeadLetterNotFound = errors.New("flux: dead letter not found")
+
+// ReplayError is an error of the replayed dead letter, which handler failed again.
+type ReplayError struct {
+	// DeadLetterID is an id of the new dead letter of the message.
+	DeadLetterID string
+	Err          error
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("replayed message failed again, dead letter %s: %v", e.DeadLetterID, e.Err)
+}
+
+func (e *ReplayError) Unwrap() error {
+	return e.Err
+}
+
+// DeadLetter is a message, which handler failed after all retries.
+type DeadLetter struct {
+	ID       string           `json:"id"`
+	NodeID   string           `json:"node_id"`
+	Port     string           `json:"port"`
+	Topic    string           `json:"topic"`
+	Error    string           `json:"error"`
+	FailedAt time.Time        `json:"failed_at"`
+	Payload  []byte           `json:"payload"`
+	Metadata message.Metadata `json:"metadata"`
+}
+
+// deadLetterStore keeps the latest dead letters for replay.
+type deadLetterStore struct {
+	mu       sync.Mutex
+	capacity int
+	letters  []DeadLetter
+}
+
+func newDeadLetterStore(capacity int) *deadLetterStore {
+	return &deadLetterStore{
+		mu:       sync.Mutex{},
+		capacity: capacity,
+		letters:  make([]DeadLetter, 0, capacity),
+	}
+}
+
+func (s *deadLetterStore) add(letter DeadLetter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.letters) >= s.capacity {
+		s.letters = slices.Delete(s.letters, 0, len(s.letters)-s.capacity+1)
+	}
+
+	s.letters = append(s.letters, letter)
+}
+
+func (s *deadLetterStore) list() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.letters)
+}
+
+func (s *deadLetterStore) take(id string) (DeadLetter, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, letter := range s.letters {
+		if letter.ID == id {
+			s.letters = slices.Delete(s.letters, i, i+1)
+			return letter, true
+		}
+	}
+
+	return DeadLetter{}, false
+}
+
+// newDeadLetter creates dead letter of the failed message, encrypted messages are kept encrypted.
+func newDeadLetter(nodeID, port string, msg *message.Message, err error) DeadLetter {
+	payload, metadata := msg.Payload, msg.Metadata
+	if original, ok := encryptedOriginalOf(msg); ok {
+		payload, metadata = original.payload, original.metadata
+	}
+
+	return DeadLetter{
+		ID:       watermill.NewUUID(),
+		NodeID:   nodeID,
+		Port:     port,
+		Topic:    message.SubscribeTopicFromCtx(msg.Context()),
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+		Payload:  payload,
+		Metadata: maps.Clone(metadata),
+	}
+}
+
+// message returns dead letter message with the original payload and error metadata.
+func (d DeadLetter) message() *message.Message {
+	msg := message.NewMessage(d.ID, d.Payload)
+	msg.Metadata = maps.Clone(d.Metadata)
+	if msg.Metadata == nil {
+		msg.Metadata = make(message.Metadata)
+	}
+
+	msg.Metadata.Set(MetadataDeadLetterError, d.Error)
+	msg.Metadata.Set(MetadataDeadLetterNode, d.NodeID)
+	msg.Metadata.Set(MetadataDeadLetterPort, d.Port)
+	msg.Metadata.Set(MetadataDeadLetterTopic, d.Topic)
+	msg.Metadata.Set(MetadataDeadLetterFailedAt, d.FailedAt.Format(time.RFC3339Nano))
+
+	return msg
+}
+
+// deadLetter stores failed message and publishes it into service.<id>.dlq topic.
+func (s *Service[T]) deadLetter(letter DeadLetter) {
+	s.deadLetters.add(letter)
+
+	pub := s.Pub()
+	if pub == nil {
+		return
+	}
+
+	if err := pub.Publish(s.topics.DeadLetters(), letter.message()); err != nil {
+		s.logger.Error("failed to publish dead letter", "node_id", letter.NodeID, "err", err.Error())
+	}
+}
+
+// DeadLetters returns the latest messages, which handlers failed after all retries.
+// It returns nil, when dead-letter queue is disabled.
+func (s *Service[T]) DeadLetters() []DeadLetter {
+	if s.deadLetters == nil {
+		return nil
+	}
+
+	return s.deadLetters.list()
+}
+
+// ReplayDeadLetter passes dead letter back into the input port of its node and removes it from the queue.
+// When handler fails again, the message becomes a new dead letter and its error is returned,
+// see ReplayError.
+func (s *Service[T]) ReplayDeadLetter(id string) error {
+	if s.deadLetters == nil {
+		return fmt.Errorf("%w: dead-letter queue is disabled", ErrDeadLetterNotFound)
+	}
+
+	letter, ok := s.deadLetters.take(id)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrDeadLetterNotFound, id)
+	}
+
+	node, ok := s.Node(letter.NodeID)
+	if !ok {
+		s.deadLetters.add(letter)
+		return fmt.Errorf("could not replay dead letter: node %s is not running", letter.NodeID)
+	}
+
+	msg := message.NewMessage(watermill.NewUUID(), letter.Payload)
+	msg.Metadata = maps.Clone(letter.Metadata)
+
+	return node.replay(letter.Port, msg)
+}
+
+// replay passes message into the input port handler, bypassing the queue.
+func (n *Node[T]) replay(port string, msg *message.Message) error {
+	n.inputsMu.RLock()
+	handle, ok := n.inputs[port]
+	n.inputsMu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("could not replay message: node %s has no handler of port %s", n.config.ID, port)
+	}
+
+	// dead letters of encrypted ports are stored encrypted
+	if err := n.decodePayload(port, msg); err != nil {
+		return fmt.Errorf("could not decode replayed message: %w", err)
+	}
+
+	err := handle(msg)
+	if err == nil {
+		return nil
+	}
+
+	return &ReplayError{DeadLetterID: n.deadLetterFailed(port, msg, err), Err: err}
+}
+
+// deadLetterFailed sends message, which handler failed, into the dead-letter queue.
+// It returns id of the dead letter, or empty string when the queue is disabled.
+func (n *Node[T]) deadLetterFailed(port string, msg *message.Message, err error) string {
+	if n.onDeadLetter == nil {
+		return ""
+	}
+
+	letter := newDeadLetter(n.config.ID, port, msg, err)
+	n.onDeadLetter(letter)
+
+	return letter.ID
+}
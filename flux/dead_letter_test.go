@@ -0,0 +1,101 @@
+package flux
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// newEncryptedNode creates node with encrypted input port "in".
+func newEncryptedNode(t *testing.T) *Node[any] {
+	t.Helper()
+
+	cipher, err := NewPayloadCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := NewNode[any](context.Background(), nil, nil, nil, NodeConfig[any]{ID: "node"})
+	node.cipher = cipher
+	node.encrypted["in"] = true
+
+	return node
+}
+
+// encryptedMessage returns message of the encrypted port "out" of the node "camera".
+func encryptedMessage(t *testing.T, node *Node[any], payload []byte) *message.Message {
+	t.Helper()
+
+	msg := message.NewMessage("id", payload)
+	msg.Metadata.Set(MetadataSourceNode, "camera")
+	msg.Metadata.Set(MetadataPort, "out")
+
+	sealed, err := node.cipher.Encrypt(msg.Payload, payloadAAD(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg.Payload = sealed
+	msg.Metadata.Set(MetadataEncryption, EncryptionAES256GCM)
+
+	return msg
+}
+
+func TestDeadLetterOfEncryptedPort(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+	secret := []byte(`{"token":"secret"}`)
+
+	node := newEncryptedNode(t)
+
+	var letters []DeadLetter
+	node.onDeadLetter = func(letter DeadLetter) { letters = append(letters, letter) }
+
+	var handled [][]byte
+	node.inputs["in"] = func(msg *message.Message) error {
+		handled = append(handled, msg.Payload)
+		return errHandler
+	}
+
+	msg := encryptedMessage(t, node, secret)
+	if err := node.decodePayload("in", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	node.deadLetterFailed("in", msg, errHandler)
+
+	if len(letters) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(letters))
+	}
+
+	letter := letters[0]
+	dlq := letter.message()
+	if bytes.Contains(letter.Payload, secret) || bytes.Contains(dlq.Payload, secret) {
+		t.Fatal("decrypted payload is dead-lettered")
+	}
+
+	if dlq.Metadata.Get(MetadataEncryption) != EncryptionAES256GCM {
+		t.Fatal("dead letter is not marked encrypted")
+	}
+
+	// replay decrypts the stored dead letter again
+	replayed := message.NewMessage("replay", letter.Payload)
+	replayed.Metadata = letter.Metadata
+
+	var replayErr *ReplayError
+	if err := node.replay("in", replayed); !errors.As(err, &replayErr) || !errors.Is(err, errHandler) {
+		t.Fatalf("replay error %v, want ReplayError of %v", err, errHandler)
+	}
+
+	if len(handled) != 1 || !bytes.Equal(handled[0], secret) {
+		t.Fatalf("handled %q, want decrypted payload", handled)
+	}
+
+	if len(letters) != 2 || bytes.Contains(letters[1].Payload, secret) {
+		t.Fatal("failed replay is not dead-lettered encrypted")
+	}
+}
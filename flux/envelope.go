@@ -0,0 +1,131 @@
+package flux
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Metadata keys of the message envelope, set by Node.Push.
+const (
+	MetadataSourceNode = "flux_source_node"
+	MetadataPort       = "flux_port"
+	MetadataSentAt     = "flux_sent_at"
+	MetadataSequence   = "flux_seq"
+	// MetadataStream is an id of the node instance, which numbers the sequence.
+	// It changes, when upstream node is restarted, so its sequence starts from the beginning.
+	MetadataStream        = "flux_stream"
+	MetadataCorrelationID = "flux_correlation_id"
+)
+
+// Envelope is a standard metadata of the message sent by node.
+//
+// Messages, which were not sent by flux node, have only MessageID and Metadata filled.
+type Envelope struct {
+	MessageID     string
+	SourceNode    string
+	Port          string
+	SentAt        time.Time
+	Sequence      uint64
+	Stream        string
+	CorrelationID string
+	Metadata      message.Metadata
+}
+
+// EnvelopeFromMessage reads envelope from message metadata.
+func EnvelopeFromMessage(msg *message.Message) Envelope {
+	env := Envelope{
+		MessageID:     msg.UUID,
+		SourceNode:    msg.Metadata.Get(MetadataSourceNode),
+		Port:          msg.Metadata.Get(MetadataPort),
+		SentAt:        time.Time{},
+		Sequence:      0,
+		Stream:        msg.Metadata.Get(MetadataStream),
+		CorrelationID: msg.Metadata.Get(MetadataCorrelationID),
+		Metadata:      msg.Metadata,
+	}
+
+	if sentAt, err := time.Parse(time.RFC3339Nano, msg.Metadata.Get(MetadataSentAt)); err == nil {
+		env.SentAt = sentAt
+	}
+
+	if seq, err := strconv.ParseUint(msg.Metadata.Get(MetadataSequence), 10, 64); err == nil {
+		env.Sequence = seq
+	}
+
+	return env
+}
+
+type envelopeKey struct{}
+
+type correlationIDKey struct{}
+
+// ContextWithEnvelope returns context with envelope of the handled message.
+func ContextWithEnvelope(ctx context.Context, env Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, env)
+}
+
+// EnvelopeFromContext returns envelope of the message, which is handled with given context.
+func EnvelopeFromContext(ctx context.Context) (Envelope, bool) {
+	env, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return env, ok
+}
+
+// ContextWithCorrelationID sets correlation id for messages pushed with the context.
+//
+// Without it, pushed messages inherit correlation id of the handled message,
+// or start a new correlation with own message id.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+func correlationIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(correlationIDKey{}).(string); ok {
+		return id
+	}
+
+	if env, ok := EnvelopeFromContext(ctx); ok {
+		return env.CorrelationID
+	}
+
+	return ""
+}
+
+// newMessage creates message with envelope metadata of the node output port,
+// except sending time and sequence, which are stamped right before publishing, see stampMessage.
+func (n *Node[T]) newMessage(ctx context.Context, port string, payload []byte) *message.Message {
+	msg := message.NewMessage(watermill.NewUUID(), payload)
+
+	correlationID := correlationIDFromContext(ctx)
+	if correlationID == "" {
+		correlationID = msg.UUID
+	}
+
+	msg.Metadata.Set(MetadataSourceNode, n.config.ID)
+	msg.Metadata.Set(MetadataPort, port)
+	msg.Metadata.Set(MetadataCorrelationID, correlationID)
+
+	return msg
+}
+
+// stampMessage sets sending time and sequence of the message, which is being published.
+// Messages dropped or coalesced by the output limiter take no sequence numbers,
+// so receivers do not count them as lost.
+func (n *Node[T]) stampMessage(port string, msg *message.Message) {
+	msg.Metadata.Set(MetadataSentAt, time.Now().UTC().Format(time.RFC3339Nano))
+	msg.Metadata.Set(MetadataSequence, strconv.FormatUint(n.nextSequence(port), 10))
+	msg.Metadata.Set(MetadataStream, n.stream)
+}
+
+// nextSequence returns next sequence number of the output port, starting from 1.
+func (n *Node[T]) nextSequence(port string) uint64 {
+	n.sequencesMu.Lock()
+	defer n.sequencesMu.Unlock()
+
+	n.sequences[port]++
+
+	return n.sequences[port]
+}
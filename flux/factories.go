@@ -4,12 +4,31 @@ import (
 	"fmt"
 
 	"github.com/ThreeDotsLabs/watermill"
-	"github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
 	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/nats-io/nats.go"
+
+	wants "github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
 
 	"github.com/flux-agi/flux_go/fluxmq"
+	"github.com/flux-agi/flux_go/fluxtrace"
 )
 
+// natsPublisher is a NATS publisher, which reports state of its connection.
+type natsPublisher struct {
+	*wants.Publisher
+	conn *nats.Conn
+}
+
+func (p natsPublisher) IsConnected() bool { return p.conn.IsConnected() }
+
+// natsSubscriber is a NATS subscriber, which reports state of its connection.
+type natsSubscriber struct {
+	*wants.Subscriber
+	conn *nats.Conn
+}
+
+func (s natsSubscriber) IsConnected() bool { return s.conn.IsConnected() }
+
 func DefaultPublisherFactory(url string) PublisherFactory {
 	return func(logger watermill.LoggerAdapter) (message.Publisher, error) {
 		logger = logger.With(watermill.LogFields{
@@ -17,23 +36,28 @@ func DefaultPublisherFactory(url string) PublisherFactory {
 			"component": "flux.publisher",
 		})
 
-		factory, err := nats.NewPublisher(
-			nats.PublisherConfig{
-				URL:               url,
-				NatsOptions:       nil,
-				Marshaler:         nil,
-				SubjectCalculator: nil,
-				JetStream: nats.JetStreamConfig{
+		conn, err := nats.Connect(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to nats: %w", err)
+		}
+
+		publisher, err := wants.NewPublisherWithNatsConn(
+			conn,
+			wants.PublisherPublishConfig{
+				Marshaler:         new(wants.NATSMarshaler),
+				SubjectCalculator: wants.DefaultSubjectCalculator,
+				JetStream: wants.JetStreamConfig{
 					Disabled: true,
 				},
 			},
 			logger,
 		)
 		if err != nil {
+			conn.Close()
 			return nil, fmt.Errorf("failed to create nats publisher: %w", err)
 		}
 
-		return factory, nil
+		return natsPublisher{Publisher: publisher, conn: conn}, nil
 	}
 }
 
@@ -44,39 +68,51 @@ func DefaultSubscriberFactory(url string) SubscriberFactory {
 			"component": "flux.subscriber",
 		})
 
-		factory, err := nats.NewSubscriber(
-			nats.SubscriberConfig{
-				URL:               url,
+		conn, err := nats.Connect(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to nats: %w", err)
+		}
+
+		subscriber, err := wants.NewSubscriberWithNatsConn(
+			conn,
+			wants.SubscriberSubscriptionConfig{
 				QueueGroupPrefix:  "",
 				SubscribersCount:  0,
 				CloseTimeout:      0,
 				AckWaitTimeout:    0,
 				SubscribeTimeout:  0,
-				NatsOptions:       nil,
 				Unmarshaler:       nil,
 				SubjectCalculator: nil,
 				NakDelay:          nil,
-				JetStream: nats.JetStreamConfig{
+				JetStream: wants.JetStreamConfig{
 					Disabled: true,
 				},
 			},
 			logger,
 		)
 		if err != nil {
+			conn.Close()
 			return nil, fmt.Errorf("failed to create nats subscriber: %w", err)
 		}
 
-		return factory, nil
+		return natsSubscriber{Subscriber: subscriber, conn: conn}, nil
 	}
 }
 
 func DefaultCallerFactory(url string) CallerFactory {
+	return TracedCallerFactory(url, nil)
+}
+
+// TracedCallerFactory creates callers, which record client spans of calls with the tracer.
+// When tracer is nil, fluxtrace.Default is used.
+func TracedCallerFactory(url string, tracer *fluxtrace.Tracer) CallerFactory {
 	return func(_ watermill.LoggerAdapter) (fluxmq.Caller, error) {
 		factory, err := fluxmq.NewNatsCaller(
 			&fluxmq.NatsCallerConfig{
 				URL:         url,
 				Marshaler:   nil,
 				Unmarshaler: nil,
+				Tracer:      tracer,
 			},
 		)
 		if err != nil {
@@ -0,0 +1,108 @@
+package flux
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// connectionChecker is implemented by clients, which can report state of NATS connection,
+// e.g. fluxmq.NatsCaller.
+type connectionChecker interface {
+	IsConnected() bool
+}
+
+// HealthReport is a response of /healthz endpoint.
+type HealthReport struct {
+	Healthy   bool   `json:"healthy"`
+	Connected bool   `json:"connected"`
+	Service   string `json:"service"`
+}
+
+// ReadinessReport is a response of /readyz endpoint.
+type ReadinessReport struct {
+	Ready         bool               `json:"ready"`
+	Service       string             `json:"service"`
+	Status        ServiceStatus      `json:"status"`
+	ConfigApplied bool               `json:"config_applied"`
+	Nodes         []NodeHealthReport `json:"nodes"`
+}
+
+// NodeHealthReport is a status of the node in readiness report.
+type NodeHealthReport struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Type   string     `json:"type"`
+	Status NodeStatus `json:"status"`
+}
+
+// Health returns whether service is connected to NATS.
+// Connections of publisher, subscriber and caller are checked, when they can report their state.
+func (s *Service[T]) Health() HealthReport {
+	s.connectionMutex.RLock()
+	connected := s.pub != nil && s.sub != nil
+	checkers := []connectionChecker{s.pubConn, s.subConn}
+	if checker, ok := s.call.(connectionChecker); ok {
+		checkers = append(checkers, checker)
+	}
+	s.connectionMutex.RUnlock()
+
+	for _, checker := range checkers {
+		if checker != nil && !checker.IsConnected() {
+			connected = false
+		}
+	}
+
+	return HealthReport{
+		Healthy:   connected,
+		Connected: connected,
+		Service:   s.serviceID,
+	}
+}
+
+// Readiness returns whether service applied config and is ready to handle messages.
+func (s *Service[T]) Readiness() ReadinessReport {
+	s.nodesMu.RLock()
+	nodes := make([]NodeHealthReport, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, NodeHealthReport{
+			ID:     node.config.ID,
+			Name:   node.config.Name,
+			Type:   node.config.Type,
+			Status: node.Status(),
+		})
+	}
+	s.nodesMu.RUnlock()
+
+	status := s.Status()
+	configApplied := s.configApplied.Load()
+
+	return ReadinessReport{
+		Ready:         configApplied && status == ServiceStatusReady,
+		Service:       s.serviceID,
+		Status:        status,
+		ConfigApplied: configApplied,
+		Nodes:         nodes,
+	}
+}
+
+func (s *Service[T]) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	report := s.Health()
+	writeJSON(w, report, report.Healthy)
+}
+
+func (s *Service[T]) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	report := s.Readiness()
+	writeJSON(w, report, report.Ready)
+}
+
+func writeJSON(w http.ResponseWriter, value any, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -33,6 +33,10 @@ func (s *Service[T]) handleIDEStatus(msg *message.Message) error {
 		return fmt.Errorf("flux: failed to unmarshal payload: %w", err)
 	}
 
+	if s.logForwarder != nil {
+		s.logForwarder.SetIDEConnected(payload.Status == IDEStatusConnected)
+	}
+
 	if s.onIDEStatus != nil {
 		err := s.onIDEStatus(payload)
 		if err != nil {
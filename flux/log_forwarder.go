@@ -0,0 +1,391 @@
+package flux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const (
+	DefaultLogForwardBuffer   = 1024
+	DefaultLogForwardBatch    = 100
+	DefaultLogForwardInterval = time.Second
+	DefaultLogForwardRate     = 100
+)
+
+// LogRecord is a log record forwarded into service.<id>.logs topic.
+type LogRecord struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Service string         `json:"service"`
+	NodeID  string         `json:"node_id,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// LogLevelMessage is a payload of service.<id>.log_level control topic.
+//
+// Empty NodeID changes level of the whole service. Empty Level removes the override.
+type LogLevelMessage struct {
+	Level  string `json:"level"`
+	NodeID string `json:"node_id"`
+}
+
+// LogForwarderOptions configures forwarding of logs to the manager.
+type LogForwarderOptions struct {
+	// Buffer is a count of records waiting for publishing, new records are dropped when it is full.
+	Buffer int
+	// Batch is a maximal count of records published in one message.
+	Batch int
+	// Interval is an interval of publishing buffered records.
+	Interval time.Duration
+	// Rate is a maximal count of forwarded records per second.
+	Rate float64
+}
+
+// LogForwarder is a slog.Handler, which publishes log records into service.<id>.logs topic.
+//
+// While IDE is connected, records from info level are forwarded, otherwise only warnings and errors.
+// The level can be changed for the service or a single node with service.<id>.log_level topic.
+type LogForwarder struct {
+	state *logForwarderState
+
+	nodeID string
+	group  string
+	attrs  []slog.Attr
+}
+
+type logForwarderState struct {
+	serviceID string
+	options   LogForwarderOptions
+	records   chan LogRecord
+	limiter   *tokenBucket
+	dropped   atomic.Uint64
+
+	ideConnected atomic.Bool
+
+	mu           sync.RWMutex
+	serviceLevel *slog.Level
+	nodeLevels   map[string]slog.Level
+}
+
+// NewLogForwarder creates log forwarder of the service. Records are published, when Run is called.
+func NewLogForwarder(serviceID string, options LogForwarderOptions) *LogForwarder {
+	if options.Buffer <= 0 {
+		options.Buffer = DefaultLogForwardBuffer
+	}
+
+	if options.Batch <= 0 {
+		options.Batch = DefaultLogForwardBatch
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = DefaultLogForwardInterval
+	}
+
+	if options.Rate <= 0 {
+		options.Rate = DefaultLogForwardRate
+	}
+
+	return &LogForwarder{
+		state: &logForwarderState{
+			serviceID:    serviceID,
+			options:      options,
+			records:      make(chan LogRecord, options.Buffer),
+			limiter:      newTokenBucket(options.Rate, int(options.Rate)),
+			dropped:      atomic.Uint64{},
+			ideConnected: atomic.Bool{},
+			mu:           sync.RWMutex{},
+			serviceLevel: nil,
+			nodeLevels:   make(map[string]slog.Level),
+		},
+		nodeID: "",
+		group:  "",
+		attrs:  nil,
+	}
+}
+
+func (f *LogForwarder) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= f.state.level(f.nodeID)
+}
+
+func (f *LogForwarder) Handle(_ context.Context, record slog.Record) error {
+	if !f.state.limiter.allow() {
+		f.state.dropped.Add(1)
+		return nil
+	}
+
+	attrs := make(map[string]any, len(f.attrs)+record.NumAttrs())
+	for _, attr := range f.attrs {
+		attrs[attr.Key] = attr.Value.Resolve().Any()
+	}
+
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs[f.key(attr.Key)] = attr.Value.Resolve().Any()
+		return true
+	})
+
+	for key, value := range attrs {
+		if err, ok := value.(error); ok {
+			attrs[key] = err.Error()
+		}
+	}
+
+	delete(attrs, "service")
+	delete(attrs, "node_id")
+
+	select {
+	case f.state.records <- LogRecord{
+		Time:    record.Time,
+		Level:   record.Level.String(),
+		Message: record.Message,
+		Service: f.state.serviceID,
+		NodeID:  f.nodeID,
+		Attrs:   attrs,
+	}:
+	default:
+		f.state.dropped.Add(1)
+	}
+
+	return nil
+}
+
+func (f *LogForwarder) WithAttrs(attrs []slog.Attr) slog.Handler { //nolint:ireturn
+	clone := *f
+	clone.attrs = make([]slog.Attr, 0, len(f.attrs)+len(attrs))
+	clone.attrs = append(clone.attrs, f.attrs...)
+
+	for _, attr := range attrs {
+		if attr.Key == "node_id" && f.group == "" {
+			clone.nodeID = attr.Value.String()
+		}
+
+		clone.attrs = append(clone.attrs, slog.Attr{Key: f.key(attr.Key), Value: attr.Value})
+	}
+
+	return &clone
+}
+
+func (f *LogForwarder) WithGroup(name string) slog.Handler { //nolint:ireturn
+	if name == "" {
+		return f
+	}
+
+	clone := *f
+	clone.group = f.key(name)
+
+	return &clone
+}
+
+func (f *LogForwarder) key(key string) string {
+	if f.group == "" {
+		return key
+	}
+
+	return f.group + "." + key
+}
+
+// Dropped returns count of records, which were dropped by rate limit or full buffer.
+func (f *LogForwarder) Dropped() uint64 {
+	return f.state.dropped.Load()
+}
+
+// SetIDEConnected switches default forwarded level.
+// When IDE disconnects, levels set with SetLevel are reset.
+func (f *LogForwarder) SetIDEConnected(connected bool) {
+	if f.state.ideConnected.Swap(connected) && !connected {
+		f.state.mu.Lock()
+		f.state.serviceLevel = nil
+		clear(f.state.nodeLevels)
+		f.state.mu.Unlock()
+	}
+}
+
+// SetLevel sets forwarded level of the node, or of the whole service when nodeID is empty.
+// Nil level removes the override.
+func (f *LogForwarder) SetLevel(nodeID string, level *slog.Level) {
+	f.state.mu.Lock()
+	defer f.state.mu.Unlock()
+
+	switch {
+	case nodeID == "":
+		f.state.serviceLevel = level
+	case level == nil:
+		delete(f.state.nodeLevels, nodeID)
+	default:
+		f.state.nodeLevels[nodeID] = *level
+	}
+}
+
+func (s *logForwarderState) level(nodeID string) slog.Level {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if level, ok := s.nodeLevels[nodeID]; ok && nodeID != "" {
+		return level
+	}
+
+	if s.serviceLevel != nil {
+		return *s.serviceLevel
+	}
+
+	if s.ideConnected.Load() {
+		return slog.LevelInfo
+	}
+
+	return slog.LevelWarn
+}
+
+// Run publishes buffered records in batches into the topic until ctx is done.
+func (f *LogForwarder) Run(ctx context.Context, topic string, pub func() message.Publisher) {
+	ticker := time.NewTicker(f.state.options.Interval)
+	defer ticker.Stop()
+
+	batch := make([]LogRecord, 0, f.state.options.Batch)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := publishLogRecords(pub(), topic, batch); err != nil {
+			f.state.dropped.Add(uint64(len(batch)))
+		}
+
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+
+		case record := <-f.state.records:
+			batch = append(batch, record)
+			if len(batch) >= f.state.options.Batch {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func publishLogRecords(pub message.Publisher, topic string, records []LogRecord) error {
+	if pub == nil {
+		return fmt.Errorf("publisher is not connected")
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("could not marshal log records: %w", err)
+	}
+
+	if err := pub.Publish(topic, message.NewMessage(watermill.NewUUID(), data)); err != nil {
+		return fmt.Errorf("could not publish log records: %w", err)
+	}
+
+	return nil
+}
+
+// teeHandler passes records to all handlers, which are enabled for the record level.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range t {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+
+	for _, handler := range t {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+
+		if err := handler.Handle(ctx, record.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("could not handle log record: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler { //nolint:ireturn
+	handlers := make(teeHandler, 0, len(t))
+	for _, handler := range t {
+		handlers = append(handlers, handler.WithAttrs(attrs))
+	}
+
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler { //nolint:ireturn
+	handlers := make(teeHandler, 0, len(t))
+	for _, handler := range t {
+		handlers = append(handlers, handler.WithGroup(name))
+	}
+
+	return handlers
+}
+
+// RegisterLogLevelHandler registers handler of service.<id>.log_level topic,
+// which changes level of logs forwarded to the manager.
+func (s *Service[T]) RegisterLogLevelHandler(router *message.Router) {
+	if s.logForwarder == nil {
+		return
+	}
+
+	router.AddNoPublisherHandler(
+		"flux.log_level",
+		s.topics.LogLevel(),
+		s.Sub(),
+		s.handleLogLevel,
+	)
+}
+
+func (s *Service[T]) handleLogLevel(msg *message.Message) error {
+	var payload LogLevelMessage
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("flux: failed to unmarshal payload: %w", err)
+	}
+
+	if payload.Level == "" {
+		s.logForwarder.SetLevel(payload.NodeID, nil)
+		return nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(payload.Level)); err != nil {
+		return fmt.Errorf("flux: invalid log level: %w", err)
+	}
+
+	s.logForwarder.SetLevel(payload.NodeID, &level)
+
+	return nil
+}
+
+// LogForwarder returns forwarder of service logs to the manager, or nil when forwarding is disabled.
+func (s *Service[T]) LogForwarder() *LogForwarder {
+	return s.logForwarder
+}
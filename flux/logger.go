@@ -0,0 +1,152 @@
+package flux
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// Env variables of log levels: debug, info, warn or error.
+const (
+	EnvLogLevel          = "FLUX_LOG_LEVEL"
+	EnvWatermillLogLevel = "FLUX_WATERMILL_LOG_LEVEL"
+)
+
+// LevelTrace is a level of watermill trace logs.
+const LevelTrace = watermill.LevelTrace
+
+// ParseLogLevel parses level name, e.g. "debug" or "WARN".
+// It returns fallback, when name is empty or unknown.
+func ParseLogLevel(name string, fallback slog.Level) slog.Level {
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(name, "trace") {
+		return LevelTrace
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return fallback
+	}
+
+	return level
+}
+
+// NewDefaultLogger creates text logger into stderr with level from FLUX_LOG_LEVEL env.
+func NewDefaultLogger(serviceID string) *slog.Logger {
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource:   false,
+		Level:       ParseLogLevel(os.Getenv(EnvLogLevel), slog.LevelInfo),
+		ReplaceAttr: nil,
+	})
+
+	return slog.New(handler).With(slog.String("service", serviceID))
+}
+
+// WatermillLogger is a watermill.LoggerAdapter, which writes into slog.
+//
+// It has own minimal level, so chatty watermill logs can be filtered out
+// without changing level of the service logger.
+type WatermillLogger struct {
+	logger *slog.Logger
+	level  slog.Leveler
+}
+
+// NewWatermillLogger creates watermill logger, which writes into logger records not below level.
+func NewWatermillLogger(logger *slog.Logger, level slog.Leveler) *WatermillLogger {
+	return &WatermillLogger{
+		logger: logger,
+		level:  level,
+	}
+}
+
+// NewDefaultWatermillLogger creates watermill logger with level from FLUX_WATERMILL_LOG_LEVEL env,
+// warn by default.
+func NewDefaultWatermillLogger(logger *slog.Logger) *WatermillLogger {
+	return NewWatermillLogger(
+		logger.With(slog.String("component", "watermill")),
+		ParseLogLevel(os.Getenv(EnvWatermillLogLevel), slog.LevelWarn),
+	)
+}
+
+func (l *WatermillLogger) Error(msg string, err error, fields watermill.LogFields) {
+	l.log(slog.LevelError, msg, append(attrsFromFields(fields), slog.Any("err", err)))
+}
+
+func (l *WatermillLogger) Info(msg string, fields watermill.LogFields) {
+	l.log(slog.LevelInfo, msg, attrsFromFields(fields))
+}
+
+func (l *WatermillLogger) Debug(msg string, fields watermill.LogFields) {
+	l.log(slog.LevelDebug, msg, attrsFromFields(fields))
+}
+
+func (l *WatermillLogger) Trace(msg string, fields watermill.LogFields) {
+	l.log(LevelTrace, msg, attrsFromFields(fields))
+}
+
+//nolint:ireturn
+func (l *WatermillLogger) With(fields watermill.LogFields) watermill.LoggerAdapter {
+	return &WatermillLogger{
+		logger: l.logger.With(attrsToArgs(attrsFromFields(fields))...),
+		level:  l.level,
+	}
+}
+
+func (l *WatermillLogger) log(level slog.Level, msg string, attrs []slog.Attr) {
+	if level < l.level.Level() {
+		return
+	}
+
+	l.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+func attrsFromFields(fields watermill.LogFields) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields)+1)
+	for key, value := range fields {
+		attrs = append(attrs, slog.Any(key, value))
+	}
+
+	return attrs
+}
+
+func attrsToArgs(attrs []slog.Attr) []any {
+	args := make([]any, 0, len(attrs))
+	for _, attr := range attrs {
+		args = append(args, attr)
+	}
+
+	return args
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns context, which carries logger.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns logger carried by context, e.g. logger of the node in subscribe handler.
+// It returns slog.Default, when context has no logger.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// Logger returns logger of the node, tagged with node id, name and type.
+func (n *Node[T]) Logger() *slog.Logger {
+	return n.logger
+}
+
+func newNodeLogger(logger *slog.Logger, id, name, nodeType string) *slog.Logger {
+	return logger.With(
+		slog.String("node_id", id),
+		slog.String("node_name", name),
+		slog.String("node_type", nodeType),
+	)
+}
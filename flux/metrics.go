@@ -0,0 +1,425 @@
+package flux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics of the SDK, all of them except chunk metrics have "node" label.
+const (
+	MetricPortMessages     = "flux_port_messages_total"
+	MetricHandlerDuration  = "flux_handler_duration_seconds"
+	MetricHandlerErrors    = "flux_handler_errors_total"
+	MetricTickDuration     = "flux_tick_duration_seconds"
+	MetricPublishErrors    = "flux_publish_errors_total"
+	MetricQueueDepth       = "flux_input_queue_depth"
+	MetricQueueDropped     = "flux_input_queue_dropped_total"
+	MetricMessagesLost     = "flux_input_messages_lost_total"
+	MetricOutputDropped    = "flux_output_dropped_total"
+	MetricOutputCoalesced  = "flux_output_coalesced_total"
+	MetricChunkedMessages  = "flux_chunked_messages_total"
+	MetricChunksIncomplete = "flux_chunk_transfers_incomplete_total"
+	MetricRejectedMessages = "flux_rejected_messages_total"
+	MetricChunksDropped    = "flux_chunks_dropped_total"
+	MetricQuarantined      = "flux_quarantined_messages_total"
+)
+
+// chunkMetrics are collected from chunking publisher and subscriber, which are recreated on reconnect.
+var chunkMetrics = []string{MetricChunkedMessages, MetricChunksIncomplete, MetricChunksDropped}
+
+// DefaultDurationBuckets are upper bounds of duration histograms in seconds.
+var DefaultDurationBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+type MetricType string
+
+const (
+	MetricTypeCounter   MetricType = "counter"
+	MetricTypeGauge     MetricType = "gauge"
+	MetricTypeHistogram MetricType = "histogram"
+)
+
+// Labels are metric labels, e.g. {"node": "camera", "port": "frame"}.
+type Labels map[string]string
+
+func (l Labels) key() string {
+	keys := slices.Sorted(maps.Keys(l))
+
+	var builder strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(key)
+		builder.WriteString(`="`)
+		builder.WriteString(escapeLabelValue(l[key]))
+		builder.WriteByte('"')
+	}
+
+	return builder.String()
+}
+
+// MetricSample is a value of one metric series.
+type MetricSample struct {
+	Labels Labels  `json:"labels"`
+	Value  float64 `json:"value"`
+	// Histogram fields, Buckets are cumulative counts per upper bound.
+	Count   uint64    `json:"count,omitempty"`
+	Sum     float64   `json:"sum,omitempty"`
+	Buckets []float64 `json:"buckets,omitempty"`
+	Counts  []uint64  `json:"counts,omitempty"`
+}
+
+// MetricFamily is a snapshot of all series of the metric.
+type MetricFamily struct {
+	Name    string         `json:"name"`
+	Help    string         `json:"help"`
+	Type    MetricType     `json:"type"`
+	Samples []MetricSample `json:"samples"`
+}
+
+type metricSeries struct {
+	labels Labels
+	value  float64
+	count  uint64
+	sum    float64
+	counts []uint64
+	// base and collected keep counters, which are collected from restarting sources, monotonic.
+	base      float64
+	collected float64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    MetricType
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+// Metrics is a registry of counters, gauges and histograms.
+//
+// It has no external dependencies and renders itself in Prometheus text format.
+// All methods are safe to call on nil registry, they do nothing.
+type Metrics struct {
+	mu         sync.Mutex
+	families   map[string]*metricFamily
+	collectors []func(m *Metrics)
+}
+
+// NewMetrics creates registry with SDK metrics registered.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		mu:         sync.Mutex{},
+		families:   make(map[string]*metricFamily),
+		collectors: nil,
+	}
+
+	m.Register(MetricPortMessages, MetricTypeCounter, "Messages handled by node ports.", nil)
+	m.Register(MetricHandlerDuration, MetricTypeHistogram, "Duration of node handlers.", DefaultDurationBuckets)
+	m.Register(MetricHandlerErrors, MetricTypeCounter, "Errors returned by node handlers.", nil)
+	m.Register(MetricTickDuration, MetricTypeHistogram, "Duration of node tick handlers.", DefaultDurationBuckets)
+	m.Register(MetricPublishErrors, MetricTypeCounter, "Errors of publishing into node output ports.", nil)
+	m.Register(MetricQueueDepth, MetricTypeGauge, "Messages waiting in the input queue.", nil)
+	m.Register(MetricQueueDropped, MetricTypeCounter, "Messages dropped by the full input queue.", nil)
+	m.Register(MetricMessagesLost, MetricTypeCounter, "Messages lost on the way from upstream ports.", nil)
+	m.Register(MetricOutputDropped, MetricTypeCounter, "Messages dropped by the output port rate limit.", nil)
+	m.Register(MetricOutputCoalesced, MetricTypeCounter, "Messages coalesced by the output port rate limit.", nil)
+	m.Register(MetricChunkedMessages, MetricTypeCounter, "Large messages split into chunks or reassembled from them.", nil)
+	m.Register(MetricChunksIncomplete, MetricTypeCounter, "Chunked transfers dropped by timeout or memory limit.", nil)
+	m.Register(MetricChunksDropped, MetricTypeCounter, "Invalid or duplicated chunks.", nil)
+	m.Register(MetricQuarantined, MetricTypeCounter, "Messages received by quarantined nodes.", nil)
+	m.Register(MetricRejectedMessages, MetricTypeCounter, "Messages rejected by signature verification.", nil)
+
+	return m
+}
+
+// Register registers metric family. Buckets are required for histograms only.
+// Registering of existing metric does nothing.
+func (m *Metrics) Register(name string, kind MetricType, help string, buckets []float64) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.families[name]; ok {
+		return
+	}
+
+	m.families[name] = &metricFamily{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		buckets: slices.Sorted(slices.Values(buckets)),
+		series:  make(map[string]*metricSeries),
+	}
+}
+
+// AddCollector adds function, which is called before every snapshot to update gauges.
+func (m *Metrics) AddCollector(collector func(m *Metrics)) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.collectors = append(m.collectors, collector)
+}
+
+// Add increases counter by value.
+func (m *Metrics) Add(name string, value float64, labels Labels) {
+	m.update(name, labels, func(_ *metricFamily, series *metricSeries) {
+		series.value += value
+	})
+}
+
+// Set sets value of gauge, see Collect for counters collected from other counters.
+func (m *Metrics) Set(name string, value float64, labels Labels) {
+	m.update(name, labels, func(_ *metricFamily, series *metricSeries) {
+		series.value = value
+	})
+}
+
+// Collect sets counter, which is collected from other counters.
+// Unlike Set, it keeps the counter monotonic, when the source counter restarts from zero,
+// e.g. after node reload or reconnect.
+func (m *Metrics) Collect(name string, value float64, labels Labels) {
+	m.update(name, labels, func(_ *metricFamily, series *metricSeries) {
+		if value < series.collected {
+			series.base += series.collected
+		}
+
+		series.collected = value
+		series.value = series.base + value
+	})
+}
+
+// restartCollected marks sources of collected counters with matching labels as restarted,
+// their next collected values are added to the current totals.
+func (m *Metrics) restartCollected(names []string, match Labels) {
+	m.eachSeries(names, match, func(family *metricFamily, key string) {
+		series := family.series[key]
+		series.base += series.collected
+		series.collected = 0
+	})
+}
+
+// deleteSeries removes series with matching labels, e.g. series of the closed node.
+func (m *Metrics) deleteSeries(names []string, match Labels) {
+	m.eachSeries(names, match, func(family *metricFamily, key string) {
+		delete(family.series, key)
+	})
+}
+
+// eachSeries calls fn with series of the named families, which labels contain match.
+// Empty names mean all families.
+func (m *Metrics) eachSeries(names []string, match Labels, fn func(family *metricFamily, key string)) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, family := range m.families {
+		if len(names) > 0 && !slices.Contains(names, name) {
+			continue
+		}
+
+		for key, series := range family.series {
+			if matchLabels(series.labels, match) {
+				fn(family, key)
+			}
+		}
+	}
+}
+
+func matchLabels(labels, match Labels) bool {
+	for key, value := range match {
+		if labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Observe adds value into histogram.
+func (m *Metrics) Observe(name string, value float64, labels Labels) {
+	m.update(name, labels, func(family *metricFamily, series *metricSeries) {
+		series.count++
+		series.sum += value
+
+		for i, bound := range family.buckets {
+			if value <= bound {
+				series.counts[i]++
+			}
+		}
+	})
+}
+
+func (m *Metrics) update(name string, labels Labels, update func(family *metricFamily, series *metricSeries)) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family, ok := m.families[name]
+	if !ok {
+		return
+	}
+
+	key := labels.key()
+
+	series, ok := family.series[key]
+	if !ok {
+		series = &metricSeries{
+			labels:    maps.Clone(labels),
+			value:     0,
+			count:     0,
+			sum:       0,
+			counts:    make([]uint64, len(family.buckets)),
+			base:      0,
+			collected: 0,
+		}
+		family.series[key] = series
+	}
+
+	update(family, series)
+}
+
+// Snapshot collects gauges and returns all metric families sorted by name.
+func (m *Metrics) Snapshot() []MetricFamily {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	collectors := slices.Clone(m.collectors)
+	m.mu.Unlock()
+
+	for _, collect := range collectors {
+		collect(m)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	families := make([]MetricFamily, 0, len(m.families))
+	for _, name := range slices.Sorted(maps.Keys(m.families)) {
+		family := m.families[name]
+
+		samples := make([]MetricSample, 0, len(family.series))
+		for _, key := range slices.Sorted(maps.Keys(family.series)) {
+			series := family.series[key]
+			samples = append(samples, MetricSample{
+				Labels:  maps.Clone(series.labels),
+				Value:   series.value,
+				Count:   series.count,
+				Sum:     series.sum,
+				Buckets: family.buckets,
+				Counts:  slices.Clone(series.counts),
+			})
+		}
+
+		if family.kind != MetricTypeHistogram {
+			for i := range samples {
+				samples[i].Buckets = nil
+				samples[i].Counts = nil
+			}
+		}
+
+		families = append(families, MetricFamily{
+			Name:    family.name,
+			Help:    family.help,
+			Type:    family.kind,
+			Samples: samples,
+		})
+	}
+
+	return families
+}
+
+// WritePrometheus writes metrics in Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+
+	for _, family := range m.Snapshot() {
+		if len(family.Samples) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buf, "# HELP %s %s\n", family.Name, family.Help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", family.Name, family.Type)
+
+		for _, sample := range family.Samples {
+			labels := sample.Labels.key()
+
+			if family.Type != MetricTypeHistogram {
+				fmt.Fprintf(buf, "%s%s %s\n", family.Name, wrapLabels(labels), formatFloat(sample.Value))
+				continue
+			}
+
+			for i, bound := range sample.Buckets {
+				le := `le="` + formatFloat(bound) + `"`
+				fmt.Fprintf(buf, "%s_bucket%s %d\n", family.Name, wrapLabels(joinLabels(labels, le)), sample.Counts[i])
+			}
+
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", family.Name, wrapLabels(joinLabels(labels, `le="+Inf"`)), sample.Count)
+			fmt.Fprintf(buf, "%s_sum%s %s\n", family.Name, wrapLabels(labels), formatFloat(sample.Sum))
+			fmt.Fprintf(buf, "%s_count%s %d\n", family.Name, wrapLabels(labels), sample.Count)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("could not write metrics: %w", err)
+	}
+
+	return nil
+}
+
+// ServeHTTP serves metrics in Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WritePrometheus(w)
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+
+	return labels + "," + label
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -11,21 +11,37 @@ import (
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
+
+	"github.com/flux-agi/flux_go/fluxtrace"
 )
 
 type (
 	NodeEventHandler = func(nodeAlias string) error
 )
 
+// SubscribeContextHandler is a subscribe handler, which receives context of the handled message.
+// Envelope of the message can be read with EnvelopeFromContext.
+type SubscribeContextHandler[T any] func(ctx context.Context, node NodeConfig[T], payload []byte) error
+
 type NodeHandlers[T any] struct {
-	onReadyHandler func(cfg NodeConfig[T]) error
-	onStartHandler NodeEventHandler
-	onStopHandler  NodeEventHandler
-	onSubscribe    map[string]func(node NodeConfig[T], payload []byte) error
-	onDestroy      func(node NodeConfig[T]) error
-	onTick         func(node NodeConfig[T], deltaTime time.Duration, timestamp time.Time) error
-	onSettings     func(node NodeConfig[T]) error
-	mu             sync.Mutex
+	onReadyHandler    func(cfg NodeConfig[T]) error
+	onStartHandler    NodeEventHandler
+	onStopHandler     NodeEventHandler
+	onSubscribe       map[string]SubscribeContextHandler[T]
+	onDestroy         func(node NodeConfig[T]) error
+	onTick            func(node NodeConfig[T], deltaTime time.Duration, timestamp time.Time) error
+	onSettings        func(node NodeConfig[T]) error
+	inputQueues       map[string]QueueSettings
+	outputLimits      map[string]OutputLimit
+	outputCompression map[string]CompressionSettings
+	encryptedPorts    map[string]bool
+	latches           map[string]time.Duration
+	onLoss            LossHandler[T]
+	lossThreshold     float64
+	breaker           BreakerSettings
+	middlewares       []scopedMiddleware
+	nodeTypes         []NodeType
+	mu                sync.Mutex
 }
 
 func (n *NodeHandlers[T]) OnReady(handler func(cfg NodeConfig[T]) error) {
@@ -41,14 +57,55 @@ func (n *NodeHandlers[T]) OnStop(handler NodeEventHandler) {
 }
 
 func (n *NodeHandlers[T]) OnSubscribe(port string, handler func(node NodeConfig[T], payload []byte) error) {
+	n.OnSubscribeContext(port, func(_ context.Context, node NodeConfig[T], payload []byte) error {
+		return handler(node, payload)
+	})
+}
+
+func (n *NodeHandlers[T]) OnSubscribeContext(port string, handler SubscribeContextHandler[T]) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	if n.onSubscribe == nil {
-		n.onSubscribe = make(map[string]func(node NodeConfig[T], payload []byte) error)
+		n.onSubscribe = make(map[string]SubscribeContextHandler[T])
 	}
 	n.onSubscribe[port] = handler
 }
 
+// SetInputQueue sets default queue settings for the input port.
+// Queue settings from the port config take precedence over it.
+func (n *NodeHandlers[T]) SetInputQueue(port string, settings QueueSettings) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.inputQueues == nil {
+		n.inputQueues = make(map[string]QueueSettings)
+	}
+	n.inputQueues[port] = settings
+}
+
+// Latch makes the input port latched: the node keeps its latest value,
+// which can be read with Node.Latest, e.g. from tick handler.
+// Zero maxAge means that value never becomes stale.
+func (n *NodeHandlers[T]) Latch(port string, maxAge time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.latches == nil {
+		n.latches = make(map[string]time.Duration)
+	}
+	n.latches[port] = maxAge
+}
+
+// OnLoss registers handler, which is called when part of messages lost
+// on the way from an upstream output port exceeds the threshold, e.g. 0.01 for 1%.
+func (n *NodeHandlers[T]) OnLoss(threshold float64, handler LossHandler[T]) {
+	n.onLoss = handler
+	n.lossThreshold = threshold
+}
+
+// SetBreaker sets quarantine settings of nodes with repeatedly failing handlers.
+func (n *NodeHandlers[T]) SetBreaker(settings BreakerSettings) {
+	n.breaker = settings
+}
+
 func (n *NodeHandlers[T]) OnSettings(handler func(node NodeConfig[T]) error) {
 	n.onSettings = handler
 }
@@ -57,7 +114,12 @@ func (n *NodeHandlers[T]) GetSubscribeHandler(port string) (func(node NodeConfig
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	handler, ok := n.onSubscribe[port]
-	return handler, ok
+	if !ok {
+		return nil, false
+	}
+	return func(node NodeConfig[T], payload []byte) error {
+		return handler(context.Background(), node, payload)
+	}, true
 }
 
 func (n *NodeHandlers[T]) OnTick(handler func(node NodeConfig[T], deltaTime time.Duration, timestamp time.Time) error) {
@@ -69,17 +131,78 @@ func (n *NodeHandlers[T]) OnDestroy(handler func(node NodeConfig[T]) error) {
 }
 
 type Node[T any] struct {
-	ctx context.Context
-
-	router *message.Router
-	pub    message.Publisher
-	sub    message.Subscriber
-	config NodeConfig[T]
-	status *AtomicValue[NodeStatus]
-	state  *AtomicValue[[]byte]
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	router  *message.Router
+	pub     message.Publisher
+	sub     message.Subscriber
+	config  NodeConfig[T]
+	status  *AtomicValue[NodeStatus]
+	state   *AtomicValue[[]byte]
+	tracer  *fluxtrace.Tracer
+	metrics *Metrics
+	logger  *slog.Logger
 
 	// Handlers
 	onDestroyHandler func(node NodeConfig[T]) error
+	// onError is called with record of every failed handler.
+	onError func(record ErrorRecord)
+	// onQuarantine is called, when node is quarantined or resumed.
+	onQuarantine func(record QuarantineRecord)
+
+	// Quarantine
+	breaker *nodeBreaker
+
+	// Middlewares of input ports
+	middlewares []scopedMiddleware
+
+	// Processing functions of input ports without dead-lettering, used for replay
+	inputs map[string]func(msg *message.Message) error
+	// Subscribe handlers of input ports, registering the port again replaces its handler
+	handlers map[string]SubscribeContextHandler[T]
+	inputsMu sync.RWMutex
+	// onDeadLetter is called with messages, which handlers failed after all retries.
+	// When it is set, failed messages are acknowledged.
+	onDeadLetter func(letter DeadLetter)
+
+	// Input queues
+	queueSettings map[string]QueueSettings
+	queues        map[string]*inputQueue
+	queuesMu      sync.RWMutex
+
+	// Topics of output ports
+	outputs map[string][]string
+	// unwiredPorts are output ports without topics, which pushes were already warned about.
+	unwiredPorts sync.Map
+	// legacyTopics makes Push publish into node/<id>/<port> topic as well.
+	legacyTopics bool
+
+	// Payload compression of output ports
+	compression map[string]CompressionSettings
+	// maxDecodedSize is a maximal size of decompressed input payloads
+	maxDecodedSize int
+	// Payload encryption of sensitive ports
+	encrypted map[string]bool
+	cipher    *PayloadCipher
+
+	// Output rate limiters
+	limiters   map[string]*outputLimiter
+	limitersMu sync.RWMutex
+
+	// Latched inputs
+	latches   map[string]*latchedInput
+	latchesMu sync.RWMutex
+
+	// Output sequences, stream is an id of this node instance, which numbers them
+	stream      string
+	sequences   map[string]uint64
+	sequencesMu sync.Mutex
+
+	// Input sequences
+	inputSequences *sequenceTracker
+	onLoss         LossHandler[T]
+	lossThreshold  float64
 
 	// Private
 	lastTick time.Time
@@ -92,21 +215,42 @@ func NewNode[T any](
 	pub message.Publisher,
 	config NodeConfig[T],
 ) *Node[T] {
+	ctx, cancel := context.WithCancel(ctx)
+
 	return &Node[T]{
-		ctx:      ctx,
-		router:   router,
-		sub:      sub,
-		pub:      pub,
-		config:   config,
-		state:    NewAtomicValue[[]byte](nil),
-		lastTick: time.Now(),
+		ctx:            ctx,
+		cancel:         cancel,
+		router:         router,
+		sub:            sub,
+		pub:            pub,
+		config:         config,
+		status:         NewAtomicValue(NodeStatusReady),
+		state:          NewAtomicValue[[]byte](nil),
+		tracer:         fluxtrace.Default(),
+		logger:         newNodeLogger(slog.Default(), config.ID, config.Name, config.Type),
+		queueSettings:  make(map[string]QueueSettings),
+		queues:         make(map[string]*inputQueue),
+		outputs:        outputTopics(config.Outputs),
+		unwiredPorts:   sync.Map{},
+		legacyTopics:   false,
+		compression:    make(map[string]CompressionSettings),
+		maxDecodedSize: DefaultMaxDecodedSize,
+		encrypted:      make(map[string]bool),
+		limiters:       make(map[string]*outputLimiter),
+		latches:        make(map[string]*latchedInput),
+		stream:         watermill.NewShortUUID(),
+		sequences:      make(map[string]uint64),
+		inputSequences: newSequenceTracker(),
+		inputs:         make(map[string]func(msg *message.Message) error),
+		handlers:       make(map[string]SubscribeContextHandler[T]),
+		lastTick:       time.Now(),
 	}
 }
 
 func (n *Node[T]) RegisterHandlers(handlers *NodeHandlers[T]) error {
 	if handlers.onReadyHandler != nil {
-		if err := handlers.onReadyHandler(n.config); err != nil {
-			return fmt.Errorf("could not run ready handler: %w", err)
+		if err := n.OnReady(handlers.onReadyHandler); err != nil {
+			return err
 		}
 	}
 
@@ -126,8 +270,35 @@ func (n *Node[T]) RegisterHandlers(handlers *NodeHandlers[T]) error {
 		n.OnTick(handlers.onTick)
 	}
 
+	n.onLoss = handlers.onLoss
+	n.lossThreshold = handlers.lossThreshold
+
+	n.middlewares = append(n.middlewares, handlers.middlewares...)
+
+	if handlers.breaker.Failures > 0 {
+		n.breaker = newNodeBreaker(handlers.breaker)
+		n.onReset()
+	}
+
+	for port, settings := range handlers.inputQueues {
+		n.queueSettings[port] = settings
+	}
+
+	n.initOutputLimiters(handlers.outputLimits)
+	n.initCompression(handlers.outputCompression)
+	n.initEncryption(handlers.encryptedPorts)
+
+	for port, maxAge := range handlers.latches {
+		if _, ok := handlers.onSubscribe[port]; ok {
+			n.setLatched(port, maxAge)
+			continue
+		}
+
+		n.Latch(port, maxAge)
+	}
+
 	for port, handler := range handlers.onSubscribe {
-		if err := n.OnSubscribe(port, handler); err != nil {
+		if err := n.OnSubscribeContext(port, handler); err != nil {
 			return fmt.Errorf("could not register subscribe handler: %w", err)
 		}
 	}
@@ -140,7 +311,10 @@ func (n *Node[T]) RegisterHandlers(handlers *NodeHandlers[T]) error {
 }
 
 func (n *Node[T]) OnReady(handler func(node NodeConfig[T]) error) error {
-	if err := handler(n.config); err != nil {
+	err := n.invoke(n.ctx, nodeEvent{name: nodeEventReady, port: ""}, nil, func(_ context.Context) error {
+		return handler(n.config)
+	})
+	if err != nil {
 		return fmt.Errorf("could not run ready handler: %w", err)
 	}
 	return nil
@@ -148,11 +322,14 @@ func (n *Node[T]) OnReady(handler func(node NodeConfig[T]) error) error {
 
 func (n *Node[T]) OnStart(handler NodeEventHandler) {
 	n.router.AddNoPublisherHandler(
-		"flux.node.on_start."+n.config.ID,
+		n.handlerName("on_start"),
 		buildTopicNodeEvent(n.config.ID, "start"),
 		n.sub,
 		func(msg *message.Message) error {
-			if err := handler(n.config.ID); err != nil {
+			err := n.invokeMessage(msg, nodeEvent{name: nodeEventStart, port: ""}, func(_ context.Context) error {
+				return handler(n.config.ID)
+			})
+			if err != nil {
 				return err
 			}
 			msg.Ack()
@@ -163,11 +340,14 @@ func (n *Node[T]) OnStart(handler NodeEventHandler) {
 
 func (n *Node[T]) OnStop(handler NodeEventHandler) {
 	n.router.AddNoPublisherHandler(
-		"flux.node.on_stop."+n.config.ID,
+		n.handlerName("on_stop"),
 		buildTopicNodeEvent(n.config.ID, "stop"),
 		n.sub,
 		func(msg *message.Message) error {
-			if err := handler(n.config.ID); err != nil {
+			err := n.invokeMessage(msg, nodeEvent{name: nodeEventStop, port: ""}, func(_ context.Context) error {
+				return handler(n.config.ID)
+			})
+			if err != nil {
 				return err
 			}
 			msg.Ack()
@@ -177,30 +357,169 @@ func (n *Node[T]) OnStop(handler NodeEventHandler) {
 }
 
 func (n *Node[T]) Push(port string, data any) error {
+	return n.PushContext(n.ctx, port, data)
+}
+
+// PushContext sends data into all topics of the output port.
+// It returns UnknownPortError, when the port is not declared in node outputs.
+//
+// When ctx is a context of the handled message, pushed message inherits its correlation id.
+func (n *Node[T]) PushContext(ctx context.Context, port string, data any) error {
+	topics, err := n.portTopics(port)
+	if err != nil {
+		return err
+	}
+
+	if len(topics) == 0 {
+		return nil
+	}
+
 	payload, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("could not marshal payload: %w", err)
 	}
 
-	return n.pub.Publish(
-		buildTopicNodePort(n.config.ID, port),
-		message.NewMessage(watermill.NewUUID(), payload),
-	)
+	ctx, span := n.tracer.Start(ctx, "flux.node.push "+port, fluxtrace.SpanKindProducer)
+	defer span.End()
+
+	span.SetAttribute("flux.node", n.config.ID)
+	span.SetAttribute("flux.port", port)
+
+	msg := n.newMessage(ctx, port, payload)
+	fluxtrace.Inject(ctx, msg)
+
+	// limiter may drop or coalesce the message, so it is stamped and encoded only when it is published
+	publish := func(msg *message.Message) error {
+		n.stampMessage(port, msg)
+
+		if err := n.encodePayload(port, msg); err != nil {
+			return err
+		}
+
+		if err := n.encryptPayload(port, msg); err != nil {
+			return err
+		}
+
+		if err := n.publishTopics(topics, msg); err != nil {
+			n.metrics.Add(MetricPublishErrors, 1, Labels{"node": n.config.ID, "port": port})
+			return err
+		}
+
+		n.metrics.Add(MetricPortMessages, 1, Labels{"node": n.config.ID, "port": port, "direction": "out"})
+
+		return nil
+	}
+
+	if limiter := n.outputLimiter(port); limiter != nil {
+		err = limiter.push(msg, publish)
+	} else {
+		err = publish(msg)
+	}
+
+	if err != nil {
+		span.SetError(err)
+		return err
+	}
+
+	return nil
 }
 
 func (n *Node[T]) OnSubscribe(port string, handler func(node NodeConfig[T], payload []byte) error) error {
+	return n.OnSubscribeContext(port, func(_ context.Context, node NodeConfig[T], payload []byte) error {
+		return handler(node, payload)
+	})
+}
+
+// OnSubscribeContext registers handler of the input port, which receives context of the handled message.
+func (n *Node[T]) OnSubscribeContext(port string, handler SubscribeContextHandler[T]) error {
 	for _, p := range n.config.Inputs {
 		if p.Alias != port {
 			continue
 		}
 
-		for _, topic := range p.Topics {
+		n.inputsMu.Lock()
+		_, registered := n.handlers[port]
+		n.handlers[port] = handler
+		n.inputsMu.Unlock()
+
+		if registered {
+			// queue and topic handlers of the port are running already and call the new handler
+			return nil
+		}
+
+		invoke := func(msg *message.Message) ([]*message.Message, error) {
+			return nil, n.invokeMessage(msg, nodeEvent{name: nodeEventSubscribe, port: port}, func(ctx context.Context) error {
+				ctx = ContextWithEnvelope(ctx, EnvelopeFromMessage(msg))
+				return n.subscribeHandler(port)(ctx, n.config, msg.Payload)
+			})
+		}
+
+		wrapped := wrapMiddlewares(invoke, n.portMiddlewares(p))
+		handle := func(msg *message.Message) error {
+			if n.breaker.isOpen() {
+				return n.rejectQuarantined(port)
+			}
+
+			err := n.callMiddlewares(port, wrapped, msg)
+			if errors.Is(err, ErrNodeQuarantined) {
+				return n.rejectQuarantined(port)
+			}
+
+			n.reportResult(nodeEvent{name: nodeEventSubscribe, port: port}, err, msg.Payload, receivedEncrypted(msg))
+			return err
+		}
+		process := func(msg *message.Message) error {
+			err := handle(msg)
+			if err == nil {
+				return nil
+			}
+
+			// messages of quarantined node are dropped, when dead-letter queue is disabled
+			if n.deadLetterFailed(port, msg, err) != "" || errors.Is(err, ErrNodeQuarantined) {
+				return nil
+			}
+			return err
+		}
+
+		n.inputsMu.Lock()
+		n.inputs[port] = handle
+		n.inputsMu.Unlock()
+
+		queue := n.inputQueue(p)
+		if queue != nil {
+			go queue.run(n.ctx, func(msg *message.Message) error {
+				err := process(msg)
+				if err != nil {
+					n.logger.Error(
+						"could not handle queued message",
+						slog.String("port", port),
+						slog.Any("err", err),
+					)
+				}
+				return err
+			})
+		}
+
+		for _, topic := range uniqueTopics(p.Topics) {
 			n.router.AddNoPublisherHandler(
-				fmt.Sprintf("flux.node.on_subscribe.%s", topic),
+				n.handlerName("on_subscribe", port, topic),
 				topic,
 				n.sub,
 				func(msg *message.Message) error {
-					if err := handler(n.config, msg.Payload); err != nil {
+					if !n.decodeReceived(port, msg) {
+						return nil
+					}
+
+					n.observeSequence(port, topic, msg)
+					n.latch(port, msg)
+
+					if queue != nil {
+						queue.push(n.ctx, msg)
+						msg.Ack()
+						return nil
+					}
+
+					if err := process(msg); err != nil {
 						return err
 					}
 					msg.Ack()
@@ -212,6 +531,57 @@ func (n *Node[T]) OnSubscribe(port string, handler func(node NodeConfig[T], payl
 	return nil
 }
 
+func (n *Node[T]) subscribeHandler(port string) SubscribeContextHandler[T] {
+	n.inputsMu.RLock()
+	defer n.inputsMu.RUnlock()
+
+	return n.handlers[port]
+}
+
+// inputQueue creates queue of the input port.
+// It returns nil, when queue is disabled for the port.
+func (n *Node[T]) inputQueue(port *Port) *inputQueue {
+	settings := n.queueSettings[port.Alias]
+	if port.Queue != nil {
+		settings = *port.Queue
+	}
+
+	if !settings.enabled() {
+		return nil
+	}
+
+	if settings.Policy != "" && !settings.Policy.valid() {
+		n.logger.Warn(
+			"unknown input queue policy, default policy is used",
+			slog.String("port", port.Alias),
+			slog.String("policy", string(settings.Policy)),
+			slog.String("default", string(QueuePolicyDropOldest)),
+		)
+	}
+
+	n.queuesMu.Lock()
+	defer n.queuesMu.Unlock()
+
+	queue := newInputQueue(settings)
+	n.queues[port.Alias] = queue
+
+	return queue
+}
+
+// InputQueueStats returns stats of the input port queue.
+// It returns false, when queue is disabled for the port.
+func (n *Node[T]) InputQueueStats(port string) (QueueStats, bool) {
+	n.queuesMu.RLock()
+	defer n.queuesMu.RUnlock()
+
+	queue, ok := n.queues[port]
+	if !ok {
+		return QueueStats{}, false
+	}
+
+	return queue.Stats(), true
+}
+
 func (n *Node[T]) OnTick(handler func(node NodeConfig[T], deltaTime time.Duration, timestamp time.Time) error) {
 	switch n.config.Timer.Type {
 	case TimerTypeNone:
@@ -224,8 +594,11 @@ func (n *Node[T]) OnTick(handler func(node NodeConfig[T], deltaTime time.Duratio
 				case <-n.ctx.Done():
 					return
 				default:
-					if err := handler(n.config, time.Since(n.lastTick), time.Now()); err != nil {
-						slog.Error("could not handle tick", slog.Any("err", err))
+					err := n.invoke(n.ctx, nodeEvent{name: nodeEventTick, port: ""}, nil, func(_ context.Context) error {
+						return handler(n.config, time.Since(n.lastTick), time.Now())
+					})
+					if err != nil {
+						n.logger.Error("could not handle tick", slog.Any("err", err))
 					}
 					time.Sleep(time.Duration(n.config.Timer.Interval) * time.Millisecond)
 				}
@@ -233,17 +606,19 @@ func (n *Node[T]) OnTick(handler func(node NodeConfig[T], deltaTime time.Duratio
 		}()
 
 	case TimerTypeGlobal:
-		n.router.AddNoPublisherHandler(
-			"flux.node.on_tick."+n.config.ID,
-			"service/tick",
-			n.sub,
-			func(msg *message.Message) error {
-				if err := handler(n.config, time.Since(n.lastTick), time.Now()); err != nil {
-					return err
-				}
-				return nil
-			},
-		)
+		// ticks of managers, which still publish into the legacy topic, are handled as well
+		for _, topic := range []string{TopicGlobalTick, TopicLegacyGlobalTick} {
+			n.router.AddNoPublisherHandler(
+				n.handlerName("on_tick", topic),
+				topic,
+				n.sub,
+				func(msg *message.Message) error {
+					return n.invokeMessage(msg, nodeEvent{name: nodeEventTick, port: ""}, func(_ context.Context) error {
+						return handler(n.config, time.Since(n.lastTick), time.Now())
+					})
+				},
+			)
+		}
 	}
 
 }
@@ -254,26 +629,25 @@ func (n *Node[T]) OnDestroy(handler func(node NodeConfig[T]) error) {
 
 func (n *Node[T]) OnSettings(handler func(settings NodeConfig[T]) error) {
 	n.router.AddNoPublisherHandler(
-		fmt.Sprintf("flux.node.%s.set_settings", n.config.ID),
-		fmt.Sprintf("node.%s.set_settings", n.config.ID),
+		n.handlerName("set_settings"),
+		NewNodeTopics(n.config.ID).Settings(),
 		n.sub,
 		func(msg *message.Message) error {
-			var settings T
-
-			err := json.Unmarshal(msg.Payload, &settings)
-			if err != nil {
-				return fmt.Errorf("could not unmarshal payload: %w", err)
-			}
-
+			// settings are not redelivered, failures are reported as error records
 			msg.Ack()
 
-			n.config.Settings = settings
+			return n.invokeMessage(msg, nodeEvent{name: nodeEventSettings, port: ""}, func(_ context.Context) error {
+				var settings T
 
-			if err := handler(n.config); err != nil {
-				return err
-			}
+				err := json.Unmarshal(msg.Payload, &settings)
+				if err != nil {
+					return fmt.Errorf("could not unmarshal settings: %w", err)
+				}
 
-			return nil
+				n.config.Settings = settings
+
+				return handler(n.config)
+			})
 		},
 	)
 }
@@ -305,16 +679,23 @@ func (n *Node[T]) SetStatus(status NodeStatus) error {
 }
 
 func (n *Node[T]) Close() error {
+	defer n.cancel()
+	defer n.closeOutputLimiters()
+	defer n.breaker.reset()
+
 	if n.onDestroyHandler != nil {
-		if destroyErr := n.onDestroyHandler(n.config); destroyErr != nil {
-			errors.Join(destroyErr, fmt.Errorf("could not run destroy handler: %w", destroyErr))
+		err := n.invoke(n.ctx, nodeEvent{name: nodeEventDestroy, port: ""}, nil, func(_ context.Context) error {
+			return n.onDestroyHandler(n.config)
+		})
+		if err != nil {
+			return fmt.Errorf("could not run destroy handler: %w", err)
 		}
 	}
 
 	return nil
 }
 
-func buildTopicNodePort(alias, port string) string { return fmt.Sprintf("node/%s/%s", alias, port) }
+func buildTopicNodePort(alias, port string) string { return NewNodeTopics(alias).Port(port) }
 func buildTopicNodeEvent(alias, event string) string {
-	return fmt.Sprintf("node/%s/event/%s", alias, event)
+	return NewNodeTopics(alias).Event(event)
 }
@@ -0,0 +1,212 @@
+package flux
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// BreakerSettings configures quarantine of the node after repeated handler failures.
+//
+// When Failures of subscribe or tick handlers happen within Window, the node is quarantined:
+// its inputs and ticks are paused. Messages received meanwhile are sent into the dead-letter queue,
+// when it is enabled, so they can be replayed after reset; otherwise they are dropped.
+// Both are counted in flux_quarantined_messages_total.
+// The node is back after Cooldown, or when manager sends reset event to node/<id>/event/reset.
+// Zero Failures disables quarantine.
+type BreakerSettings struct {
+	Failures int           `json:"failures"`
+	Window   time.Duration `json:"window"`
+	Cooldown time.Duration `json:"cooldown"`
+}
+
+// ErrNodeQuarantined is an error of messages, which were received by quarantined node.
+var ErrNodeQuarantined = errors.New("flux: node is quarantined")
+
+// QuarantineRecord is a notification about node quarantine, published into service.<id>.quarantine topic.
+type QuarantineRecord struct {
+	Service     string    `json:"service"`
+	NodeID      string    `json:"node_id"`
+	Quarantined bool      `json:"quarantined"`
+	Reason      string    `json:"reason,omitempty"`
+	Until       time.Time `json:"until,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+type nodeBreaker struct {
+	settings BreakerSettings
+
+	mu          sync.Mutex
+	failures    []time.Time
+	quarantined bool
+	reason      string
+	until       time.Time
+	timer       *time.Timer
+}
+
+func newNodeBreaker(settings BreakerSettings) *nodeBreaker {
+	return &nodeBreaker{
+		settings:    settings,
+		mu:          sync.Mutex{},
+		failures:    make([]time.Time, 0, settings.Failures),
+		quarantined: false,
+		reason:      "",
+		until:       time.Time{},
+		timer:       nil,
+	}
+}
+
+func (b *nodeBreaker) isOpen() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.quarantined
+}
+
+// failure registers handler failure, it returns true when node should be quarantined.
+func (b *nodeBreaker) failure(err error) bool {
+	if b == nil || b.settings.Failures <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.quarantined {
+		return false
+	}
+
+	now := time.Now()
+
+	recent := b.failures[:0]
+	for _, failure := range b.failures {
+		if b.settings.Window <= 0 || now.Sub(failure) <= b.settings.Window {
+			recent = append(recent, failure)
+		}
+	}
+	b.failures = append(recent, now)
+
+	if len(b.failures) < b.settings.Failures {
+		return false
+	}
+
+	b.quarantined = true
+	b.reason = fmt.Sprintf("%d failures within %s, last: %s", len(b.failures), b.settings.Window, err)
+	b.failures = b.failures[:0]
+
+	if b.settings.Cooldown > 0 {
+		b.until = now.Add(b.settings.Cooldown)
+	}
+
+	return true
+}
+
+// reset closes the breaker, it returns false when node was not quarantined.
+func (b *nodeBreaker) reset() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	wasQuarantined := b.quarantined
+	b.quarantined = false
+	b.reason = ""
+	b.until = time.Time{}
+	b.failures = b.failures[:0]
+
+	return wasQuarantined
+}
+
+// Quarantined returns whether node is quarantined after repeated failures, and the reason.
+func (n *Node[T]) Quarantined() (bool, string) {
+	if n.breaker == nil {
+		return false, ""
+	}
+
+	n.breaker.mu.Lock()
+	defer n.breaker.mu.Unlock()
+
+	return n.breaker.quarantined, n.breaker.reason
+}
+
+// quarantine pauses the node after repeated failures and schedules its reset.
+func (n *Node[T]) quarantine() {
+	n.breaker.mu.Lock()
+	record := QuarantineRecord{
+		Service:     "",
+		NodeID:      n.config.ID,
+		Quarantined: true,
+		Reason:      n.breaker.reason,
+		Until:       n.breaker.until,
+		Timestamp:   time.Now().UTC(),
+	}
+
+	if n.breaker.settings.Cooldown > 0 {
+		n.breaker.timer = time.AfterFunc(n.breaker.settings.Cooldown, n.ResetQuarantine)
+	}
+	n.breaker.mu.Unlock()
+
+	n.status.Set(NodeStatusError)
+	n.logger.Error("node is quarantined", slog.String("reason", record.Reason))
+
+	if n.onQuarantine != nil {
+		n.onQuarantine(record)
+	}
+}
+
+// rejectQuarantined counts message received by quarantined node and returns ErrNodeQuarantined, see BreakerSettings.
+func (n *Node[T]) rejectQuarantined(port string) error {
+	n.metrics.Add(MetricQuarantined, 1, Labels{"node": n.config.ID, "port": port})
+
+	return ErrNodeQuarantined
+}
+
+// ResetQuarantine resumes quarantined node.
+func (n *Node[T]) ResetQuarantine() {
+	if !n.breaker.reset() {
+		return
+	}
+
+	n.status.Set(NodeStatusReady)
+	n.logger.Info("node quarantine is reset")
+
+	if n.onQuarantine != nil {
+		n.onQuarantine(QuarantineRecord{
+			Service:     "",
+			NodeID:      n.config.ID,
+			Quarantined: false,
+			Reason:      "",
+			Until:       time.Time{},
+			Timestamp:   time.Now().UTC(),
+		})
+	}
+}
+
+// onReset subscribes on reset event of the manager, which resumes quarantined node.
+func (n *Node[T]) onReset() {
+	n.router.AddNoPublisherHandler(
+		n.handlerName("on_reset"),
+		buildTopicNodeEvent(n.config.ID, "reset"),
+		n.sub,
+		func(msg *message.Message) error {
+			n.ResetQuarantine()
+			msg.Ack()
+			return nil
+		},
+	)
+}
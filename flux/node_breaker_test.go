@@ -0,0 +1,96 @@
+package flux
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNodeBreakerFailure(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+
+	tests := []struct {
+		name     string
+		settings BreakerSettings
+		// gaps are delays before each failure
+		gaps []time.Duration
+		want []bool
+	}{
+		{
+			name:     "disabled",
+			settings: BreakerSettings{Failures: 0, Window: 0, Cooldown: 0},
+			gaps:     []time.Duration{0, 0, 0},
+			want:     []bool{false, false, false},
+		},
+		{
+			name:     "opens on the threshold",
+			settings: BreakerSettings{Failures: 3, Window: time.Minute, Cooldown: 0},
+			gaps:     []time.Duration{0, 0, 0},
+			want:     []bool{false, false, true},
+		},
+		{
+			name:     "open breaker ignores failures",
+			settings: BreakerSettings{Failures: 1, Window: time.Minute, Cooldown: 0},
+			gaps:     []time.Duration{0, 0},
+			want:     []bool{true, false},
+		},
+		{
+			name:     "failures outside the window are forgotten",
+			settings: BreakerSettings{Failures: 2, Window: 20 * time.Millisecond, Cooldown: 0},
+			gaps:     []time.Duration{0, 40 * time.Millisecond, 0},
+			want:     []bool{false, false, true},
+		},
+		{
+			name:     "zero window keeps all failures",
+			settings: BreakerSettings{Failures: 2, Window: 0, Cooldown: 0},
+			gaps:     []time.Duration{0, 20 * time.Millisecond},
+			want:     []bool{false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			breaker := newNodeBreaker(tt.settings)
+			for i, gap := range tt.gaps {
+				time.Sleep(gap)
+
+				if got := breaker.failure(errHandler); got != tt.want[i] {
+					t.Fatalf("failure %d = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNodeBreakerReset(t *testing.T) {
+	t.Parallel()
+
+	breaker := newNodeBreaker(BreakerSettings{Failures: 1, Window: time.Minute, Cooldown: time.Minute})
+	if breaker.reset() {
+		t.Fatal("reset of closed breaker returned true")
+	}
+
+	if !breaker.failure(errors.New("handler failed")) {
+		t.Fatal("breaker is not opened")
+	}
+	if !breaker.isOpen() || breaker.until.IsZero() {
+		t.Fatalf("breaker is not quarantined until cooldown: open %v, until %v", breaker.isOpen(), breaker.until)
+	}
+
+	breaker.timer = time.AfterFunc(time.Minute, func() {})
+	if !breaker.reset() {
+		t.Fatal("reset of open breaker returned false")
+	}
+	if breaker.isOpen() || breaker.timer != nil || breaker.reason != "" {
+		t.Fatal("breaker is not closed by reset")
+	}
+
+	var nilBreaker *nodeBreaker
+	if nilBreaker.isOpen() || nilBreaker.failure(errors.New("handler failed")) || nilBreaker.reset() {
+		t.Fatal("nil breaker must be always closed")
+	}
+}
@@ -22,6 +22,16 @@ type NodeConfig[T any] struct {
 type Port struct {
 	Alias  string   `json:"alias"`
 	Topics []string `json:"topics"`
+	// Queue is a bounded queue settings of the input port, optional.
+	Queue *QueueSettings `json:"queue,omitempty"`
+	// Middleware is a ready-made middlewares of the input port, optional.
+	Middleware *PortMiddleware `json:"middleware,omitempty"`
+	// Limit is a rate limit of the output port, optional.
+	Limit *OutputLimit `json:"limit,omitempty"`
+	// Compression is a payload compression of the output port, optional.
+	Compression *CompressionSettings `json:"compression,omitempty"`
+	// Encrypt makes payloads of the port encrypted, see WithPayloadEncryption.
+	Encrypt bool `json:"encrypt,omitempty"`
 }
 
 // TickSettings is a local tick settings of node.
@@ -0,0 +1,164 @@
+package flux
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+
+	"github.com/flux-agi/flux_go/fluxtrace"
+)
+
+// Node handler events.
+const (
+	nodeEventSubscribe = "subscribe"
+	nodeEventTick      = "tick"
+	nodeEventSettings  = "settings"
+	nodeEventStart     = "start"
+	nodeEventStop      = "stop"
+	nodeEventReady     = "ready"
+	nodeEventDestroy   = "destroy"
+	nodeEventLoss      = "loss"
+)
+
+// nodeEvent describes invocation of the node handler.
+type nodeEvent struct {
+	name string
+	// port is set for subscribe handlers only.
+	port string
+}
+
+func (e nodeEvent) spanName() string {
+	if e.port != "" {
+		return "flux.node." + e.name + " " + e.port
+	}
+
+	return "flux.node." + e.name
+}
+
+// handlerName returns name of the router handler of the node: flux.node.<id>.<kind>[.<part>...].
+// Names are unique per node, so nodes can subscribe on the same topics.
+func (n *Node[T]) handlerName(kind string, parts ...string) string {
+	return strings.Join(append([]string{"flux.node", n.config.ID, kind}, parts...), ".")
+}
+
+// invoke calls node handler inside a span, which continues trace carried by ctx,
+// records its metrics and reports its error. Payload is a handled message payload, if any.
+// Subscribe handlers may be retried by port middlewares, so their errors are reported by the port, see reportResult.
+//
+// Panic of the handler is recovered and returned as *PanicError, the node is marked with error status,
+// so other nodes of the service keep running.
+func (n *Node[T]) invoke(
+	ctx context.Context,
+	event nodeEvent,
+	payload []byte,
+	handler func(ctx context.Context) error,
+) error {
+	if n.breaker.isOpen() {
+		switch event.name {
+		case nodeEventSubscribe:
+			// node was quarantined while message was handled by port middlewares
+			return ErrNodeQuarantined
+		case nodeEventTick:
+			return nil
+		}
+	}
+
+	kind := fluxtrace.SpanKindConsumer
+	if event.name == nodeEventTick {
+		kind = fluxtrace.SpanKindInternal
+	}
+
+	ctx, span := n.tracer.Start(ctx, event.spanName(), kind)
+	defer span.End()
+
+	span.SetAttribute("flux.node", n.config.ID)
+	span.SetAttribute("flux.node_type", n.config.Type)
+	if event.port != "" {
+		span.SetAttribute("flux.port", event.port)
+	}
+
+	ctx = ContextWithLogger(ctx, n.logger)
+
+	started := time.Now()
+	err := callRecovered(ctx, handler)
+	n.recordDuration(event, time.Since(started))
+	span.SetError(err)
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		n.status.Set(NodeStatusError)
+		n.logger.Error(
+			"node handler panicked",
+			slog.String("event", event.name),
+			slog.String("port", event.port),
+			slog.Any("panic", panicErr.Value),
+			slog.String("stack", string(panicErr.Stack)),
+		)
+	}
+
+	if event.name != nodeEventSubscribe {
+		n.reportResult(event, err, payload, false)
+	}
+
+	return err
+}
+
+// reportResult counts handled message and reports error of the handler:
+// error metric and record, and failure of the breaker for subscribe and tick handlers.
+// It is called once per handled message, after port middlewares, e.g. retries, gave up.
+func (n *Node[T]) reportResult(event nodeEvent, err error, payload []byte, encrypted bool) {
+	labels := Labels{"node": n.config.ID, "event": event.name}
+	if event.name == nodeEventSubscribe {
+		labels["port"] = event.port
+		n.metrics.Add(MetricPortMessages, 1, Labels{"node": n.config.ID, "port": event.port, "direction": "in"})
+	}
+
+	if err == nil {
+		return
+	}
+
+	n.metrics.Add(MetricHandlerErrors, 1, labels)
+
+	if n.onError != nil {
+		n.onError(newErrorRecord("", n.config.ID, event, err, payload, encrypted))
+	}
+
+	if (event.name == nodeEventSubscribe || event.name == nodeEventTick) && n.breaker.failure(err) {
+		n.quarantine()
+	}
+}
+
+// callRecovered calls handler and converts its panic into *PanicError.
+func callRecovered(ctx context.Context, handler func(ctx context.Context) error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = NewPanicError(value)
+		}
+	}()
+
+	return handler(ctx)
+}
+
+// invokeMessage calls node handler of the received message,
+// which continues trace of the message sender.
+func (n *Node[T]) invokeMessage(msg *message.Message, event nodeEvent, handler func(ctx context.Context) error) error {
+	return n.invoke(fluxtrace.Extract(msg.Context(), msg), event, msg.Payload, handler)
+}
+
+func (n *Node[T]) recordDuration(event nodeEvent, duration time.Duration) {
+	if event.name == nodeEventTick {
+		n.metrics.Observe(MetricTickDuration, duration.Seconds(), Labels{"node": n.config.ID})
+		return
+	}
+
+	labels := Labels{"node": n.config.ID, "event": event.name}
+	if event.name == nodeEventSubscribe {
+		labels["port"] = event.port
+	}
+
+	n.metrics.Observe(MetricHandlerDuration, duration.Seconds(), labels)
+}
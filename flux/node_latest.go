@@ -0,0 +1,137 @@
+package flux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ErrNoLatestValue is returned, when latched input port has not received any value yet.
+var ErrNoLatestValue = errors.New("flux: no latest value on port")
+
+// LatestValue is the most recent value received on the latched input port.
+type LatestValue struct {
+	Payload    []byte
+	ReceivedAt time.Time
+	Age        time.Duration
+	// Stale is true, when the value is older than max age of the port.
+	Stale bool
+}
+
+type latchedInput struct {
+	maxAge time.Duration
+
+	mu       sync.RWMutex
+	payload  []byte
+	received time.Time
+}
+
+func (l *latchedInput) set(payload []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.payload = payload
+	l.received = time.Now()
+}
+
+func (l *latchedInput) get() (LatestValue, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.received.IsZero() {
+		return LatestValue{}, false
+	}
+
+	age := time.Since(l.received)
+
+	return LatestValue{
+		Payload:    l.payload,
+		ReceivedAt: l.received,
+		Age:        age,
+		Stale:      l.maxAge > 0 && age > l.maxAge,
+	}, true
+}
+
+// Latch subscribes on the input port in latched mode: every received value replaces
+// the previous one and can be read with Latest. Zero maxAge means that value never becomes stale.
+//
+// Use NodeHandlers.Latch for ports that also have a subscribe handler,
+// so the handler is still called for every message.
+func (n *Node[T]) Latch(port string, maxAge time.Duration) {
+	n.setLatched(port, maxAge)
+
+	for _, p := range n.config.Inputs {
+		if p.Alias != port {
+			continue
+		}
+
+		for _, topic := range uniqueTopics(p.Topics) {
+			n.router.AddNoPublisherHandler(
+				n.handlerName("on_latch", port, topic),
+				topic,
+				n.sub,
+				func(msg *message.Message) error {
+					if !n.decodeReceived(port, msg) {
+						return nil
+					}
+
+					n.observeSequence(port, topic, msg)
+					n.latch(port, msg)
+					msg.Ack()
+					return nil
+				},
+			)
+		}
+	}
+}
+
+// Latest returns the most recent value of the latched input port.
+// It returns false, when port is not latched or has not received any value yet.
+func (n *Node[T]) Latest(port string) (LatestValue, bool) {
+	n.latchesMu.RLock()
+	latched, ok := n.latches[port]
+	n.latchesMu.RUnlock()
+
+	if !ok {
+		return LatestValue{}, false
+	}
+
+	return latched.get()
+}
+
+func (n *Node[T]) setLatched(port string, maxAge time.Duration) {
+	n.latchesMu.Lock()
+	defer n.latchesMu.Unlock()
+
+	n.latches[port] = &latchedInput{maxAge: maxAge}
+}
+
+func (n *Node[T]) latch(port string, msg *message.Message) {
+	n.latchesMu.RLock()
+	latched, ok := n.latches[port]
+	n.latchesMu.RUnlock()
+
+	if ok {
+		latched.set(msg.Payload)
+	}
+}
+
+// LatestAs returns the most recent value of the latched input port decoded from json.
+func LatestAs[V any, T any](node *Node[T], port string) (V, LatestValue, error) {
+	var value V
+
+	latest, ok := node.Latest(port)
+	if !ok {
+		return value, latest, fmt.Errorf("%w: %s", ErrNoLatestValue, port)
+	}
+
+	if err := json.Unmarshal(latest.Payload, &value); err != nil {
+		return value, latest, fmt.Errorf("could not unmarshal latest value: %w", err)
+	}
+
+	return value, latest, nil
+}
@@ -0,0 +1,115 @@
+package flux
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestNodeLatest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		maxAge   time.Duration
+		payloads []string
+		// wait is a delay after the last value, before it is read
+		wait      time.Duration
+		want      string
+		wantOK    bool
+		wantStale bool
+	}{
+		{
+			name:      "no value",
+			maxAge:    0,
+			payloads:  nil,
+			wait:      0,
+			want:      "",
+			wantOK:    false,
+			wantStale: false,
+		},
+		{
+			name:      "last value wins",
+			maxAge:    time.Minute,
+			payloads:  []string{"1", "2", "3"},
+			wait:      0,
+			want:      "3",
+			wantOK:    true,
+			wantStale: false,
+		},
+		{
+			name:      "value older than max age is stale",
+			maxAge:    10 * time.Millisecond,
+			payloads:  []string{"1"},
+			wait:      30 * time.Millisecond,
+			want:      "1",
+			wantOK:    true,
+			wantStale: true,
+		},
+		{
+			name:      "zero max age never becomes stale",
+			maxAge:    0,
+			payloads:  []string{"1"},
+			wait:      30 * time.Millisecond,
+			want:      "1",
+			wantOK:    true,
+			wantStale: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			node := NewNode[any](context.Background(), nil, nil, nil, NodeConfig[any]{ID: "node"})
+			node.setLatched("in", tt.maxAge)
+
+			for _, payload := range tt.payloads {
+				node.latch("in", message.NewMessage(payload, []byte(payload)))
+			}
+
+			time.Sleep(tt.wait)
+
+			got, ok := node.Latest("in")
+			if ok != tt.wantOK {
+				t.Fatalf("latest is present %v, want %v", ok, tt.wantOK)
+			}
+
+			if string(got.Payload) != tt.want || got.Stale != tt.wantStale {
+				t.Fatalf("latest %q stale %v, want %q stale %v", got.Payload, got.Stale, tt.want, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestNodeLatestUnlatchedPort(t *testing.T) {
+	t.Parallel()
+
+	node := NewNode[any](context.Background(), nil, nil, nil, NodeConfig[any]{ID: "node"})
+	node.latch("in", message.NewMessage("1", []byte("1")))
+
+	if _, ok := node.Latest("in"); ok {
+		t.Fatal("value is latched on port, which is not latched")
+	}
+}
+
+func TestLatestAs(t *testing.T) {
+	t.Parallel()
+
+	node := NewNode[any](context.Background(), nil, nil, nil, NodeConfig[any]{ID: "node"})
+	node.setLatched("in", 0)
+
+	if _, _, err := LatestAs[int](node, "in"); !errors.Is(err, ErrNoLatestValue) {
+		t.Fatalf("error %v, want %v", err, ErrNoLatestValue)
+	}
+
+	node.latch("in", message.NewMessage("1", []byte(`42`)))
+
+	value, _, err := LatestAs[int](node, "in")
+	if err != nil || value != 42 {
+		t.Fatalf("latest %d, %v, want 42", value, err)
+	}
+}
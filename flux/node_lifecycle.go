@@ -1,6 +1,10 @@
 package flux
 
-import "time"
+import (
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
 
 func (s *Service[T]) OnNodeReady(handler func(cfg NodeConfig[T]) error) {
 	s.nodeHandlers.OnReady(handler)
@@ -20,6 +24,9 @@ func (s *Service[T]) OnNodeDestroy(handler func(node NodeConfig[T]) error) {
 
 func (s *Service[T]) OnNodeSubscribe(port string, handler func(node NodeConfig[T], payload []byte) error) error {
 	s.nodeHandlers.OnSubscribe(port, handler)
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+
 	for _, node := range s.nodes {
 		if err := node.OnSubscribe(port, handler); err != nil {
 			return err
@@ -28,10 +35,88 @@ func (s *Service[T]) OnNodeSubscribe(port string, handler func(node NodeConfig[T
 	return nil
 }
 
+// OnNodeSubscribeContext registers handler of the input port, which receives context of the handled message.
+func (s *Service[T]) OnNodeSubscribeContext(port string, handler SubscribeContextHandler[T]) error {
+	s.nodeHandlers.OnSubscribeContext(port, handler)
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+
+	for _, node := range s.nodes {
+		if err := node.OnSubscribeContext(port, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Service[T]) OnNodeTick(handler func(node NodeConfig[T], deltaTime time.Duration, timestamp time.Time) error) {
 	s.nodeHandlers.OnTick(handler)
 }
 
+// OnNodeLoss registers handler, which is called when loss ratio of an upstream port exceeds the threshold.
+func (s *Service[T]) OnNodeLoss(threshold float64, handler LossHandler[T]) {
+	s.nodeHandlers.OnLoss(threshold, handler)
+}
+
 func (s *Service[T]) OnNodeSettings(handler func(node NodeConfig[T]) error) {
 	s.nodeHandlers.OnSettings(handler)
 }
+
+// SetNodeInputQueue sets default bounded queue settings for the input port of all nodes.
+func (s *Service[T]) SetNodeInputQueue(port string, settings QueueSettings) {
+	s.nodeHandlers.SetInputQueue(port, settings)
+}
+
+// SetNodeOutputLimit sets default rate limit of the output port of all nodes.
+func (s *Service[T]) SetNodeOutputLimit(port string, limit OutputLimit) {
+	s.nodeHandlers.SetOutputLimit(port, limit)
+}
+
+// SetNodeOutputCompression sets default payload compression of the output port of all nodes.
+func (s *Service[T]) SetNodeOutputCompression(port string, settings CompressionSettings) {
+	s.nodeHandlers.SetOutputCompression(port, settings)
+}
+
+// EncryptNodePort makes the port of all nodes encrypted.
+func (s *Service[T]) EncryptNodePort(port string) {
+	s.nodeHandlers.EncryptPort(port)
+}
+
+// LatchNodeInput makes the input port of all nodes latched.
+// Latest value of the port can be read with Node.Latest or LatestAs.
+func (s *Service[T]) LatchNodeInput(port string, maxAge time.Duration) {
+	s.nodeHandlers.Latch(port, maxAge)
+}
+
+// SetNodeBreaker sets quarantine settings of nodes with repeatedly failing handlers.
+func (s *Service[T]) SetNodeBreaker(settings BreakerSettings) {
+	s.nodeHandlers.SetBreaker(settings)
+}
+
+// UseNodeMiddleware registers middlewares of input ports of all nodes.
+func (s *Service[T]) UseNodeMiddleware(middlewares ...message.HandlerMiddleware) {
+	s.nodeHandlers.Use(middlewares...)
+}
+
+// UseNodeTypeMiddleware registers middlewares of input ports of nodes with given type.
+func (s *Service[T]) UseNodeTypeMiddleware(nodeType string, middlewares ...message.HandlerMiddleware) {
+	s.nodeHandlers.UseForNodeType(nodeType, middlewares...)
+}
+
+// UsePortMiddleware registers middlewares of the input port of all nodes.
+func (s *Service[T]) UsePortMiddleware(port string, middlewares ...message.HandlerMiddleware) {
+	s.nodeHandlers.UseForPort(port, middlewares...)
+}
+
+// Node returns running node by its id.
+func (s *Service[T]) Node(id string) (*Node[T], bool) {
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+
+	for _, node := range s.nodes {
+		if node.config.ID == id {
+			return node, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,270 @@
+package flux
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// LimitMode defines how output port limiter handles messages pushed over the rate.
+type LimitMode string
+
+const (
+	// LimitModeTokenBucket drops messages, when bucket is empty.
+	LimitModeTokenBucket LimitMode = "TOKEN_BUCKET"
+	// LimitModeCoalesce publishes not more than rate messages per second,
+	// messages pushed in between are coalesced into the latest one.
+	LimitModeCoalesce LimitMode = "COALESCE"
+	// LimitModeDebounce publishes the latest message, when nothing was pushed within debounce interval.
+	LimitModeDebounce LimitMode = "DEBOUNCE"
+)
+
+// OutputLimit is a rate limit settings of the output port.
+type OutputLimit struct {
+	Mode LimitMode `json:"mode"`
+	// RatePerSecond is a rate of token bucket and coalesce modes.
+	RatePerSecond float64 `json:"rate_per_second"`
+	// Burst is a size of token bucket, at least one.
+	Burst int `json:"burst"`
+	// DebounceMs is a quiet interval of debounce mode.
+	DebounceMs int `json:"debounce_ms"`
+}
+
+func (l OutputLimit) enabled() bool {
+	switch l.Mode {
+	case LimitModeTokenBucket, LimitModeCoalesce:
+		return l.RatePerSecond > 0
+	case LimitModeDebounce:
+		return l.DebounceMs > 0
+	default:
+		return false
+	}
+}
+
+// OutputLimitStats is a snapshot of the output port limiter counters.
+type OutputLimitStats struct {
+	Mode      LimitMode `json:"mode"`
+	Published uint64    `json:"published"`
+	Dropped   uint64    `json:"dropped"`
+	Coalesced uint64    `json:"coalesced"`
+}
+
+type outputLimiter struct {
+	settings OutputLimit
+	bucket   *tokenBucket
+	interval time.Duration
+	// onError is called with errors of delayed publishing.
+	onError func(err error)
+
+	mu             sync.Mutex
+	pending        *message.Message
+	pendingPublish func(msg *message.Message) error
+	timer          *time.Timer
+	next           time.Time
+	closed         bool
+	stats          OutputLimitStats
+}
+
+func newOutputLimiter(settings OutputLimit, onError func(err error)) *outputLimiter {
+	limiter := &outputLimiter{
+		settings:       settings,
+		bucket:         nil,
+		interval:       0,
+		onError:        onError,
+		mu:             sync.Mutex{},
+		pending:        nil,
+		pendingPublish: nil,
+		timer:          nil,
+		next:           time.Time{},
+		closed:         false,
+		stats:          OutputLimitStats{Mode: settings.Mode, Published: 0, Dropped: 0, Coalesced: 0},
+	}
+
+	switch settings.Mode {
+	case LimitModeTokenBucket:
+		limiter.bucket = newTokenBucket(settings.RatePerSecond, settings.Burst)
+	case LimitModeCoalesce:
+		limiter.interval = time.Duration(float64(time.Second) / settings.RatePerSecond)
+	case LimitModeDebounce:
+		limiter.interval = time.Duration(settings.DebounceMs) * time.Millisecond
+	}
+
+	return limiter
+}
+
+// push publishes message or delays it according to the limit mode.
+// Errors of delayed publishing are passed to onError.
+func (l *outputLimiter) push(msg *message.Message, publish func(msg *message.Message) error) error {
+	switch l.settings.Mode {
+	case LimitModeTokenBucket:
+		l.mu.Lock()
+		allowed := l.bucket.allow()
+		if allowed {
+			l.stats.Published++
+		} else {
+			l.stats.Dropped++
+		}
+		l.mu.Unlock()
+
+		if !allowed {
+			return nil
+		}
+
+		return publish(msg)
+
+	case LimitModeCoalesce:
+		l.mu.Lock()
+		now := time.Now()
+		if l.pending == nil && !now.Before(l.next) {
+			l.next = now.Add(l.interval)
+			l.stats.Published++
+			l.mu.Unlock()
+
+			return publish(msg)
+		}
+
+		l.delay(msg, publish, l.next.Sub(now))
+		l.mu.Unlock()
+
+		return nil
+
+	default:
+		l.mu.Lock()
+		l.delay(msg, publish, l.interval)
+		l.mu.Unlock()
+
+		return nil
+	}
+}
+
+// delay makes message pending and schedules flush, l.mu must be held.
+// Debounce reschedules flush on every message.
+func (l *outputLimiter) delay(msg *message.Message, publish func(msg *message.Message) error, after time.Duration) {
+	if l.closed {
+		l.stats.Dropped++
+		return
+	}
+
+	if l.pending != nil {
+		l.stats.Coalesced++
+	}
+
+	l.pending = msg
+	l.pendingPublish = publish
+
+	switch {
+	case l.timer == nil:
+		l.timer = time.AfterFunc(after, l.flush)
+	case l.settings.Mode == LimitModeDebounce:
+		l.timer.Reset(after)
+	}
+}
+
+func (l *outputLimiter) flush() {
+	l.mu.Lock()
+	msg, publish := l.pending, l.pendingPublish
+	l.pending, l.pendingPublish, l.timer = nil, nil, nil
+	if msg == nil || l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.next = time.Now().Add(l.interval)
+	l.stats.Published++
+	l.mu.Unlock()
+
+	if err := publish(msg); err != nil && l.onError != nil {
+		l.onError(err)
+	}
+}
+
+// close stops delayed publishing, pending message is dropped.
+func (l *outputLimiter) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+
+	if l.pending != nil {
+		l.pending, l.pendingPublish = nil, nil
+		l.stats.Dropped++
+	}
+}
+
+func (l *outputLimiter) Stats() OutputLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stats
+}
+
+// SetOutputLimit sets default rate limit of the output port.
+// Limit from the port config takes precedence over it.
+func (n *NodeHandlers[T]) SetOutputLimit(port string, limit OutputLimit) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.outputLimits == nil {
+		n.outputLimits = make(map[string]OutputLimit)
+	}
+	n.outputLimits[port] = limit
+}
+
+// initOutputLimiters creates limiters of output ports from handlers and port config.
+func (n *Node[T]) initOutputLimiters(defaults map[string]OutputLimit) {
+	settings := make(map[string]OutputLimit, len(defaults))
+	for port, limit := range defaults {
+		settings[port] = limit
+	}
+
+	for _, p := range n.config.Outputs {
+		if p.Limit != nil {
+			settings[p.Alias] = *p.Limit
+		}
+	}
+
+	n.limitersMu.Lock()
+	defer n.limitersMu.Unlock()
+
+	for port, limit := range settings {
+		if !limit.enabled() {
+			continue
+		}
+
+		n.limiters[port] = newOutputLimiter(limit, func(err error) {
+			n.logger.Error("failed to publish limited message", "port", port, "err", err.Error())
+		})
+	}
+}
+
+func (n *Node[T]) outputLimiter(port string) *outputLimiter {
+	n.limitersMu.RLock()
+	defer n.limitersMu.RUnlock()
+
+	return n.limiters[port]
+}
+
+// OutputLimitStats returns stats of the output port limiter.
+// It returns false, when limit is disabled for the port.
+func (n *Node[T]) OutputLimitStats(port string) (OutputLimitStats, bool) {
+	limiter := n.outputLimiter(port)
+	if limiter == nil {
+		return OutputLimitStats{}, false
+	}
+
+	return limiter.Stats(), true
+}
+
+// closeOutputLimiters stops delayed publishing of all output ports.
+func (n *Node[T]) closeOutputLimiters() {
+	n.limitersMu.RLock()
+	defer n.limitersMu.RUnlock()
+
+	for _, limiter := range n.limiters {
+		limiter.close()
+	}
+}
@@ -0,0 +1,154 @@
+package flux
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// publishedMessages collects messages published by the output limiter.
+type publishedMessages struct {
+	mu   sync.Mutex
+	uids []string
+}
+
+func (p *publishedMessages) publish(msg *message.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.uids = append(p.uids, msg.UUID)
+
+	return nil
+}
+
+func (p *publishedMessages) list() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.uids...)
+}
+
+func TestOutputLimiterPush(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		limit OutputLimit
+		// wait is a delay after the pushes, before stats are checked
+		wait      time.Duration
+		want      []string
+		wantStats OutputLimitStats
+	}{
+		{
+			name:      "token bucket drops over burst",
+			limit:     OutputLimit{Mode: LimitModeTokenBucket, RatePerSecond: 1, Burst: 2, DebounceMs: 0},
+			wait:      0,
+			want:      []string{"1", "2"},
+			wantStats: OutputLimitStats{Mode: LimitModeTokenBucket, Published: 2, Dropped: 2, Coalesced: 0},
+		},
+		{
+			name:      "coalesce publishes the first and the latest",
+			limit:     OutputLimit{Mode: LimitModeCoalesce, RatePerSecond: 20, Burst: 0, DebounceMs: 0},
+			wait:      150 * time.Millisecond,
+			want:      []string{"1", "4"},
+			wantStats: OutputLimitStats{Mode: LimitModeCoalesce, Published: 2, Dropped: 0, Coalesced: 2},
+		},
+		{
+			name:      "debounce publishes the latest",
+			limit:     OutputLimit{Mode: LimitModeDebounce, RatePerSecond: 0, Burst: 0, DebounceMs: 20},
+			wait:      150 * time.Millisecond,
+			want:      []string{"4"},
+			wantStats: OutputLimitStats{Mode: LimitModeDebounce, Published: 1, Dropped: 0, Coalesced: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			limiter := newOutputLimiter(tt.limit, func(err error) { t.Error(err) })
+			defer limiter.close()
+
+			published := &publishedMessages{mu: sync.Mutex{}, uids: nil}
+			for _, uid := range []string{"1", "2", "3", "4"} {
+				if err := limiter.push(message.NewMessage(uid, nil), published.publish); err != nil {
+					t.Fatalf("push %s: %v", uid, err)
+				}
+			}
+
+			time.Sleep(tt.wait)
+
+			got := published.list()
+			if len(got) != len(tt.want) {
+				t.Fatalf("published %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("published %v, want %v", got, tt.want)
+				}
+			}
+
+			if stats := limiter.Stats(); stats != tt.wantStats {
+				t.Fatalf("stats %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestOutputLimiterClose(t *testing.T) {
+	t.Parallel()
+
+	limiter := newOutputLimiter(
+		OutputLimit{Mode: LimitModeDebounce, RatePerSecond: 0, Burst: 0, DebounceMs: 20},
+		func(err error) { t.Error(err) },
+	)
+	published := &publishedMessages{mu: sync.Mutex{}, uids: nil}
+
+	if err := limiter.push(message.NewMessage("1", nil), published.publish); err != nil {
+		t.Fatal(err)
+	}
+	limiter.close()
+
+	if err := limiter.push(message.NewMessage("2", nil), published.publish); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if got := published.list(); len(got) != 0 {
+		t.Fatalf("closed limiter published %v", got)
+	}
+	if stats := limiter.Stats(); stats.Dropped != 2 {
+		t.Fatalf("dropped %d, want 2", stats.Dropped)
+	}
+}
+
+func TestOutputLimiterDelayedError(t *testing.T) {
+	t.Parallel()
+
+	errPublish := errors.New("publish failed")
+	errs := make(chan error, 1)
+
+	limiter := newOutputLimiter(
+		OutputLimit{Mode: LimitModeDebounce, RatePerSecond: 0, Burst: 0, DebounceMs: 10},
+		func(err error) { errs <- err },
+	)
+	defer limiter.close()
+
+	err := limiter.push(message.NewMessage("1", nil), func(_ *message.Message) error { return errPublish })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errPublish) {
+			t.Fatalf("error %v, want %v", err, errPublish)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error of delayed publishing is not reported")
+	}
+}
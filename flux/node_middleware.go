@@ -0,0 +1,275 @@
+package flux
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+// PortMiddleware configures ready-made middlewares of the input port in node config.
+// They are applied after middlewares registered in code, in order: dedup, throttle, retry, timeout.
+type PortMiddleware struct {
+	// TimeoutMs cancels context of the handled message after timeout.
+	TimeoutMs int `json:"timeout_ms"`
+	// Retry retries failed handler with exponential backoff.
+	Retry *RetrySettings `json:"retry,omitempty"`
+	// ThrottlePerSecond limits count of handled messages per second.
+	ThrottlePerSecond float64 `json:"throttle_per_second"`
+	// DedupWindowMs drops messages with the same id received within the window.
+	DedupWindowMs int `json:"dedup_window_ms"`
+}
+
+// RetrySettings configures retries of the failed handler.
+type RetrySettings struct {
+	MaxRetries        int     `json:"max_retries"`
+	InitialIntervalMs int     `json:"initial_interval_ms"`
+	MaxIntervalMs     int     `json:"max_interval_ms"`
+	Multiplier        float64 `json:"multiplier"`
+}
+
+// middlewares returns chain of middlewares described by port config,
+// their background work is stopped, when ctx of the node is done.
+func (m *PortMiddleware) middlewares(ctx context.Context) []message.HandlerMiddleware {
+	if m == nil {
+		return nil
+	}
+
+	chain := make([]message.HandlerMiddleware, 0, 4)
+
+	if m.DedupWindowMs > 0 {
+		chain = append(chain, dedupMiddleware(ctx, time.Duration(m.DedupWindowMs)*time.Millisecond))
+	}
+
+	if m.ThrottlePerSecond > 0 {
+		chain = append(chain, ThrottleMiddleware(m.ThrottlePerSecond))
+	}
+
+	if m.Retry != nil && m.Retry.MaxRetries > 0 {
+		chain = append(chain, RetryMiddleware(*m.Retry))
+	}
+
+	if m.TimeoutMs > 0 {
+		chain = append(chain, TimeoutMiddleware(time.Duration(m.TimeoutMs)*time.Millisecond))
+	}
+
+	return chain
+}
+
+// TimeoutMiddleware cancels context of the handled message after timeout.
+// Handlers should listen on ctx.Done() to stop in time.
+func TimeoutMiddleware(timeout time.Duration) message.HandlerMiddleware {
+	return middleware.Timeout(timeout)
+}
+
+// RetryMiddleware retries failed handler with exponential backoff.
+func RetryMiddleware(settings RetrySettings) message.HandlerMiddleware {
+	retry := middleware.Retry{
+		MaxRetries:          settings.MaxRetries,
+		InitialInterval:     time.Duration(settings.InitialIntervalMs) * time.Millisecond,
+		MaxInterval:         time.Duration(settings.MaxIntervalMs) * time.Millisecond,
+		Multiplier:          settings.Multiplier,
+		MaxElapsedTime:      0,
+		RandomizationFactor: 0,
+		OnRetryHook:         nil,
+		Logger:              nil,
+	}
+
+	if retry.InitialInterval <= 0 {
+		retry.InitialInterval = 100 * time.Millisecond
+	}
+
+	if retry.MaxInterval <= 0 {
+		retry.MaxInterval = 10 * time.Second
+	}
+
+	if retry.Multiplier <= 0 {
+		retry.Multiplier = 2
+	}
+
+	return retry.Middleware
+}
+
+// ThrottleMiddleware limits count of handled messages per second, waiting messages are delayed.
+func ThrottleMiddleware(perSecond float64) message.HandlerMiddleware {
+	return middleware.NewThrottle(1, time.Duration(float64(time.Second)/perSecond)).Middleware
+}
+
+// DedupMiddleware drops messages with the same id received by the same node input port within the window,
+// e.g. redelivered or duplicated by the publisher. Window must be at least a millisecond.
+//
+// Messages are told apart per node and port, so the middleware can be shared by all of them.
+// Expired ids are cleaned up for the lifetime of the process, see PortMiddleware.DedupWindowMs
+// for deduplication bound to the node.
+func DedupMiddleware(window time.Duration) message.HandlerMiddleware {
+	return dedupMiddleware(context.Background(), window)
+}
+
+func dedupMiddleware(ctx context.Context, window time.Duration) message.HandlerMiddleware {
+	deduplicator := &middleware.Deduplicator{
+		KeyFactory: dedupKey,
+		Repository: newExpiringKeys(ctx, max(window, time.Millisecond)),
+		Timeout:    0,
+	}
+
+	return deduplicator.Middleware
+}
+
+type inputKey struct{}
+
+// input is a node input port, which handles the message.
+type input struct {
+	nodeID string
+	port   string
+}
+
+// dedupKey returns key of the message, which is unique per node input port.
+func dedupKey(msg *message.Message) (string, error) {
+	in, _ := msg.Context().Value(inputKey{}).(input)
+
+	return in.nodeID + "/" + in.port + "/" + msg.UUID, nil
+}
+
+// expiringKeys remembers keys for the window, expired keys are cleaned up until ctx is done.
+type expiringKeys struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	keys map[string]time.Time
+}
+
+func newExpiringKeys(ctx context.Context, window time.Duration) *expiringKeys {
+	k := &expiringKeys{
+		window: window,
+		mu:     sync.Mutex{},
+		keys:   make(map[string]time.Time),
+	}
+
+	go k.cleanup(ctx)
+
+	return k
+}
+
+// IsDuplicate reports whether the key was seen within the window, otherwise it is remembered.
+func (k *expiringKeys) IsDuplicate(_ context.Context, key string) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	if seen, ok := k.keys[key]; ok && now.Sub(seen) < k.window {
+		return true, nil
+	}
+
+	k.keys[key] = now
+
+	return false, nil
+}
+
+func (k *expiringKeys) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(k.window / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case now := <-ticker.C:
+			k.mu.Lock()
+			for key, seen := range k.keys {
+				if now.Sub(seen) >= k.window {
+					delete(k.keys, key)
+				}
+			}
+			k.mu.Unlock()
+		}
+	}
+}
+
+type scopedMiddleware struct {
+	// nodeType and port limit the scope of middleware, empty means any.
+	nodeType   string
+	port       string
+	middleware message.HandlerMiddleware
+}
+
+// Use registers middlewares of input ports of all nodes.
+func (n *NodeHandlers[T]) Use(middlewares ...message.HandlerMiddleware) {
+	n.use("", "", middlewares)
+}
+
+// UseForNodeType registers middlewares of input ports of nodes with given type.
+func (n *NodeHandlers[T]) UseForNodeType(nodeType string, middlewares ...message.HandlerMiddleware) {
+	n.use(nodeType, "", middlewares)
+}
+
+// UseForPort registers middlewares of the input port.
+func (n *NodeHandlers[T]) UseForPort(port string, middlewares ...message.HandlerMiddleware) {
+	n.use("", port, middlewares)
+}
+
+func (n *NodeHandlers[T]) use(nodeType, port string, middlewares []message.HandlerMiddleware) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, m := range middlewares {
+		n.middlewares = append(n.middlewares, scopedMiddleware{nodeType: nodeType, port: port, middleware: m})
+	}
+}
+
+// portMiddlewares returns middlewares of the node input port, from the outermost.
+func (n *Node[T]) portMiddlewares(port *Port) []message.HandlerMiddleware {
+	chain := make([]message.HandlerMiddleware, 0, len(n.middlewares))
+
+	// global middlewares go first, then node type and port ones
+	for _, scope := range []func(m scopedMiddleware) bool{
+		func(m scopedMiddleware) bool { return m.nodeType == "" && m.port == "" },
+		func(m scopedMiddleware) bool { return m.nodeType != "" && m.nodeType == n.config.Type },
+		func(m scopedMiddleware) bool { return m.port != "" && m.port == port.Alias },
+	} {
+		for _, m := range n.middlewares {
+			if scope(m) {
+				chain = append(chain, m.middleware)
+			}
+		}
+	}
+
+	return append(chain, port.Middleware.middlewares(n.ctx)...)
+}
+
+// callMiddlewares calls handler of the input port wrapped with middlewares.
+// Panic of a middleware is recovered and returned as *PanicError, panics of the handler are recovered by invoke.
+func (n *Node[T]) callMiddlewares(port string, wrapped message.HandlerFunc, msg *message.Message) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			panicErr := NewPanicError(value)
+			err = panicErr
+
+			n.status.Set(NodeStatusError)
+			n.logger.Error(
+				"port middleware panicked",
+				slog.String("port", port),
+				slog.Any("panic", panicErr.Value),
+				slog.String("stack", string(panicErr.Stack)),
+			)
+		}
+	}()
+
+	msg.SetContext(context.WithValue(msg.Context(), inputKey{}, input{nodeID: n.config.ID, port: port}))
+
+	_, err = wrapped(msg)
+
+	return err
+}
+
+// wrapMiddlewares wraps handler with middlewares, the first one is the outermost.
+func wrapMiddlewares(handler message.HandlerFunc, middlewares []message.HandlerMiddleware) message.HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
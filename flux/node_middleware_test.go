@@ -0,0 +1,103 @@
+package flux
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestDedupMiddleware(t *testing.T) {
+	t.Parallel()
+
+	type delivery struct {
+		nodeID string
+		port   string
+		uuid   string
+	}
+
+	tests := []struct {
+		name       string
+		deliveries []delivery
+		want       int
+	}{
+		{
+			name:       "duplicate of the same port",
+			deliveries: []delivery{{"a", "in", "1"}, {"a", "in", "1"}, {"a", "in", "2"}},
+			want:       2,
+		},
+		{
+			name:       "two ports of one node consume the same topic",
+			deliveries: []delivery{{"a", "left", "1"}, {"a", "right", "1"}},
+			want:       2,
+		},
+		{
+			name:       "two nodes consume the same topic",
+			deliveries: []delivery{{"a", "in", "1"}, {"b", "in", "1"}, {"b", "in", "1"}},
+			want:       2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// one middleware is shared by all nodes and ports, like one registered with Use
+			dedup := DedupMiddleware(time.Minute)
+
+			nodes := map[string]*Node[any]{
+				"a": NewNode[any](context.Background(), nil, nil, nil, NodeConfig[any]{ID: "a"}),
+				"b": NewNode[any](context.Background(), nil, nil, nil, NodeConfig[any]{ID: "b"}),
+			}
+
+			handled := 0
+			wrapped := wrapMiddlewares(func(_ *message.Message) ([]*message.Message, error) {
+				handled++
+				return nil, nil
+			}, []message.HandlerMiddleware{dedup})
+
+			for _, d := range tt.deliveries {
+				// fan-out publishes copies, which keep the message id
+				if err := nodes[d.nodeID].callMiddlewares(d.port, wrapped, message.NewMessage(d.uuid, nil)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if handled != tt.want {
+				t.Fatalf("handled %d messages, want %d", handled, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiringKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keys := newExpiringKeys(ctx, 20*time.Millisecond)
+
+	if dup, _ := keys.IsDuplicate(ctx, "k"); dup {
+		t.Fatal("new key is duplicate")
+	}
+
+	if dup, _ := keys.IsDuplicate(ctx, "k"); !dup {
+		t.Fatal("repeated key is not duplicate")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	keys.mu.Lock()
+	remembered := len(keys.keys)
+	keys.mu.Unlock()
+
+	if remembered != 0 {
+		t.Fatalf("%d expired keys are not cleaned up", remembered)
+	}
+
+	if dup, _ := keys.IsDuplicate(ctx, "k"); dup {
+		t.Fatal("expired key is duplicate")
+	}
+}
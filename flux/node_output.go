@@ -0,0 +1,96 @@
+package flux
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+var ErrUnknownOutputPort = errors.New("flux: unknown output port")
+
+// UnknownPortError is returned by Node.Push, when the port is not declared in node outputs.
+type UnknownPortError struct {
+	NodeID string
+	Port   string
+}
+
+func (e *UnknownPortError) Error() string {
+	return fmt.Sprintf("%s %q of node %s", ErrUnknownOutputPort, e.Port, e.NodeID)
+}
+
+func (e *UnknownPortError) Is(target error) bool {
+	return target == ErrUnknownOutputPort
+}
+
+// outputTopics returns topics of the output ports from node config, without duplicates.
+func outputTopics(ports []*Port) map[string][]string {
+	topics := make(map[string][]string, len(ports))
+
+	for _, p := range ports {
+		if p == nil {
+			continue
+		}
+
+		topics[p.Alias] = uniqueTopics(append(topics[p.Alias], p.Topics...))
+	}
+
+	return topics
+}
+
+// uniqueTopics returns non-empty topics without duplicates, in the original order.
+func uniqueTopics(topics []string) []string {
+	unique := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if topic != "" && !slices.Contains(unique, topic) {
+			unique = append(unique, topic)
+		}
+	}
+
+	return unique
+}
+
+// portTopics returns topics, where message of the output port is published.
+// Declared port without topics is not connected to other nodes, pushes into it are discarded
+// and warned about once.
+//
+// In legacy mode node/<id>/<port> topic is used as well, and undeclared ports are allowed.
+func (n *Node[T]) portTopics(port string) ([]string, error) {
+	topics, ok := n.outputs[port]
+
+	if n.legacyTopics {
+		return append(slices.Clone(topics), buildTopicNodePort(n.config.ID, port)), nil
+	}
+
+	if !ok {
+		return nil, &UnknownPortError{NodeID: n.config.ID, Port: port}
+	}
+
+	if len(topics) == 0 {
+		if _, warned := n.unwiredPorts.LoadOrStore(port, true); !warned {
+			n.logger.Warn("output port has no topics, pushed messages are discarded", slog.String("port", port))
+		}
+	}
+
+	return topics, nil
+}
+
+// publishTopics publishes message into all topics, every topic gets its own copy of the message.
+func (n *Node[T]) publishTopics(topics []string, msg *message.Message) error {
+	var errs []error
+
+	for i, topic := range topics {
+		out := msg
+		if i < len(topics)-1 {
+			out = msg.Copy()
+		}
+
+		if err := n.pub.Publish(topic, out); err != nil {
+			errs = append(errs, fmt.Errorf("could not publish into %s: %w", topic, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,121 @@
+package flux
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// topicPublisher collects topics of published messages.
+type topicPublisher struct {
+	mu     sync.Mutex
+	topics []string
+	uuids  []string
+}
+
+func (p *topicPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, msg := range messages {
+		p.topics = append(p.topics, topic)
+		p.uuids = append(p.uuids, msg.UUID)
+	}
+
+	return nil
+}
+
+func (p *topicPublisher) Close() error {
+	return nil
+}
+
+func TestNodePushTopics(t *testing.T) {
+	t.Parallel()
+
+	output := func(alias string, topics ...string) *Port {
+		return &Port{Alias: alias, Topics: topics}
+	}
+
+	tests := []struct {
+		name    string
+		outputs []*Port
+		legacy  bool
+		port    string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "every configured topic",
+			outputs: []*Port{output("out", "t1", "t2")},
+			legacy:  false,
+			port:    "out",
+			want:    []string{"t1", "t2"},
+			wantErr: nil,
+		},
+		{
+			name:    "duplicate topics are published once",
+			outputs: []*Port{output("out", "t1", "", "t1"), output("out", "t2")},
+			legacy:  false,
+			port:    "out",
+			want:    []string{"t1", "t2"},
+			wantErr: nil,
+		},
+		{
+			name:    "port without topics",
+			outputs: []*Port{output("out")},
+			legacy:  false,
+			port:    "out",
+			want:    nil,
+			wantErr: nil,
+		},
+		{
+			name:    "unknown port",
+			outputs: []*Port{output("out", "t1")},
+			legacy:  false,
+			port:    "other",
+			want:    nil,
+			wantErr: ErrUnknownOutputPort,
+		},
+		{
+			name:    "legacy topic",
+			outputs: []*Port{output("out", "t1")},
+			legacy:  true,
+			port:    "other",
+			want:    []string{buildTopicNodePort("node", "other")},
+			wantErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pub := &topicPublisher{mu: sync.Mutex{}, topics: nil, uuids: nil}
+			node := NewNode[any](context.Background(), nil, nil, pub, NodeConfig[any]{ID: "node", Outputs: tt.outputs})
+			node.legacyTopics = tt.legacy
+
+			err := node.Push(tt.port, "value")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+
+			var portErr *UnknownPortError
+			if tt.wantErr != nil && (!errors.As(err, &portErr) || portErr.Port != tt.port || portErr.NodeID != "node") {
+				t.Fatalf("error %v is not UnknownPortError of port %s", err, tt.port)
+			}
+
+			if !slices.Equal(pub.topics, tt.want) {
+				t.Fatalf("published into %v, want %v", pub.topics, tt.want)
+			}
+
+			// every topic gets a copy of the same message
+			if len(slices.Compact(slices.Clone(pub.uuids))) > 1 {
+				t.Fatalf("copies have different uuids %v", pub.uuids)
+			}
+		})
+	}
+}
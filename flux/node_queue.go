@@ -0,0 +1,195 @@
+package flux
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// QueuePolicy defines what input queue does with a new message when it is full.
+type QueuePolicy string
+
+const (
+	// QueuePolicyDropOldest removes the oldest queued message to make room for the new one.
+	QueuePolicyDropOldest QueuePolicy = "DROP_OLDEST"
+	// QueuePolicyDropNewest discards the new message and keeps the queue as is.
+	QueuePolicyDropNewest QueuePolicy = "DROP_NEWEST"
+	// QueuePolicyKeepLatest keeps only the latest message, depth is ignored.
+	QueuePolicyKeepLatest QueuePolicy = "KEEP_LATEST"
+	// QueuePolicyBlock blocks the subscriber until the handler frees space in the queue.
+	QueuePolicyBlock QueuePolicy = "BLOCK"
+)
+
+func (p QueuePolicy) valid() bool {
+	switch p {
+	case QueuePolicyDropOldest, QueuePolicyDropNewest, QueuePolicyKeepLatest, QueuePolicyBlock:
+		return true
+	default:
+		return false
+	}
+}
+
+// QueueSettings is a bounded queue settings of the input port.
+//
+// Zero Depth means that queue is disabled and messages are handled one by one
+// right in the subscriber, like before.
+//
+// Queued messages are acknowledged, when they are queued, so delivery of a queued port is at-most-once:
+// messages dropped by the policy or failed by the handler are not redelivered.
+// Failed messages are logged and counted in QueueStats.Failed, or sent into the dead-letter queue, when it is enabled.
+type QueueSettings struct {
+	Depth  int         `json:"depth"`
+	Policy QueuePolicy `json:"policy"`
+}
+
+func (s QueueSettings) enabled() bool {
+	return s.Depth > 0 || s.Policy == QueuePolicyKeepLatest
+}
+
+func (s QueueSettings) capacity() int {
+	if s.Policy == QueuePolicyKeepLatest {
+		return 1
+	}
+
+	return s.Depth
+}
+
+// QueueStats is a snapshot of the input queue counters.
+type QueueStats struct {
+	Depth     int         `json:"depth"`
+	Capacity  int         `json:"capacity"`
+	Policy    QueuePolicy `json:"policy"`
+	Enqueued  uint64      `json:"enqueued"`
+	Processed uint64      `json:"processed"`
+	Dropped   uint64      `json:"dropped"`
+	Failed    uint64      `json:"failed"`
+}
+
+type inputQueue struct {
+	settings QueueSettings
+
+	mu    sync.Mutex
+	items []*message.Message
+	stats QueueStats
+
+	// signal wakes up the worker, when a message was queued.
+	signal chan struct{}
+	// space wakes up blocked producers, when a message was taken from the queue.
+	space chan struct{}
+}
+
+// newInputQueue creates queue, empty or unknown policy falls back to QueuePolicyDropOldest.
+func newInputQueue(settings QueueSettings) *inputQueue {
+	if !settings.Policy.valid() {
+		settings.Policy = QueuePolicyDropOldest
+	}
+
+	return &inputQueue{
+		settings: settings,
+		items:    make([]*message.Message, 0, settings.capacity()),
+		stats: QueueStats{
+			Capacity: settings.capacity(),
+			Policy:   settings.Policy,
+		},
+		signal: make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+	}
+}
+
+// push adds message to the queue according to the policy.
+// It returns false, when message was dropped.
+func (q *inputQueue) push(ctx context.Context, msg *message.Message) bool {
+	q.mu.Lock()
+	for len(q.items) >= q.settings.capacity() {
+		switch q.settings.Policy {
+		case QueuePolicyDropNewest:
+			q.stats.Dropped++
+			q.mu.Unlock()
+			return false
+
+		case QueuePolicyBlock:
+			q.mu.Unlock()
+			select {
+			case <-q.space:
+			case <-ctx.Done():
+				return false
+			}
+			q.mu.Lock()
+
+		case QueuePolicyDropOldest, QueuePolicyKeepLatest:
+			q.items[0] = nil
+			q.items = q.items[1:]
+			q.stats.Dropped++
+
+		default:
+			q.stats.Dropped++
+			q.mu.Unlock()
+			return false
+		}
+	}
+
+	q.items = append(q.items, msg)
+	q.stats.Enqueued++
+	q.mu.Unlock()
+
+	notify(q.signal)
+
+	return true
+}
+
+func (q *inputQueue) pop() (*message.Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return nil, false
+	}
+
+	msg := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	q.stats.Processed++
+
+	notify(q.space)
+
+	return msg, true
+}
+
+// run processes queued messages until context is done.
+func (q *inputQueue) run(ctx context.Context, process func(msg *message.Message) error) {
+	for {
+		msg, ok := q.pop()
+		if ok {
+			if err := process(msg); err != nil {
+				q.mu.Lock()
+				q.stats.Failed++
+				q.mu.Unlock()
+			}
+			continue
+		}
+
+		select {
+		case <-q.signal:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *inputQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := q.stats
+	stats.Depth = len(q.items)
+
+	return stats
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,149 @@
+package flux
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func queuedUUIDs(q *inputQueue) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	uids := make([]string, 0, len(q.items))
+	for _, msg := range q.items {
+		uids = append(uids, msg.UUID)
+	}
+
+	return uids
+}
+
+func TestInputQueuePush(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		settings QueueSettings
+		// want are UUIDs of queued messages after pushing 1, 2 and 3
+		want        []string
+		wantPolicy  QueuePolicy
+		wantDropped uint64
+	}{
+		{
+			name:        "drop oldest",
+			settings:    QueueSettings{Depth: 2, Policy: QueuePolicyDropOldest},
+			want:        []string{"2", "3"},
+			wantPolicy:  QueuePolicyDropOldest,
+			wantDropped: 1,
+		},
+		{
+			name:        "drop newest",
+			settings:    QueueSettings{Depth: 2, Policy: QueuePolicyDropNewest},
+			want:        []string{"1", "2"},
+			wantPolicy:  QueuePolicyDropNewest,
+			wantDropped: 1,
+		},
+		{
+			name:        "keep latest",
+			settings:    QueueSettings{Depth: 5, Policy: QueuePolicyKeepLatest},
+			want:        []string{"3"},
+			wantPolicy:  QueuePolicyKeepLatest,
+			wantDropped: 2,
+		},
+		{
+			name:        "empty policy",
+			settings:    QueueSettings{Depth: 2, Policy: ""},
+			want:        []string{"2", "3"},
+			wantPolicy:  QueuePolicyDropOldest,
+			wantDropped: 1,
+		},
+		{
+			name:        "unknown policy",
+			settings:    QueueSettings{Depth: 2, Policy: "DROP_OLD"},
+			want:        []string{"2", "3"},
+			wantPolicy:  QueuePolicyDropOldest,
+			wantDropped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := newInputQueue(tt.settings)
+			for _, uid := range []string{"1", "2", "3"} {
+				q.push(context.Background(), message.NewMessage(uid, nil))
+			}
+
+			if got := queuedUUIDs(q); !slices.Equal(got, tt.want) {
+				t.Fatalf("queued %v, want %v", got, tt.want)
+			}
+
+			stats := q.Stats()
+			if stats.Policy != tt.wantPolicy || stats.Dropped != tt.wantDropped {
+				t.Fatalf("stats %+v, want policy %s and %d dropped", stats, tt.wantPolicy, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestInputQueueBlock(t *testing.T) {
+	t.Parallel()
+
+	q := newInputQueue(QueueSettings{Depth: 1, Policy: QueuePolicyBlock})
+	if !q.push(context.Background(), message.NewMessage("1", nil)) {
+		t.Fatal("message is dropped by empty queue")
+	}
+
+	pushed := make(chan bool, 1)
+	go func() { pushed <- q.push(context.Background(), message.NewMessage("2", nil)) }()
+
+	select {
+	case <-pushed:
+		t.Fatal("push into full queue is not blocked")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if msg, ok := q.pop(); !ok || msg.UUID != "1" {
+		t.Fatal("queued message is not popped")
+	}
+
+	select {
+	case ok := <-pushed:
+		if !ok {
+			t.Fatal("blocked message is dropped")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("push is not released, when space is freed")
+	}
+}
+
+func TestInputQueueBlockCancel(t *testing.T) {
+	t.Parallel()
+
+	q := newInputQueue(QueueSettings{Depth: 1, Policy: QueuePolicyBlock})
+	q.push(context.Background(), message.NewMessage("1", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pushed := make(chan bool, 1)
+	go func() { pushed <- q.push(ctx, message.NewMessage("2", nil)) }()
+
+	cancel()
+
+	select {
+	case ok := <-pushed:
+		if ok {
+			t.Fatal("message is queued after context is cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("push is not released, when context is cancelled")
+	}
+
+	if got := queuedUUIDs(q); !slices.Equal(got, []string{"1"}) {
+		t.Fatalf("queued %v, want [1]", got)
+	}
+}
@@ -0,0 +1,207 @@
+package flux
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// sequenceWindow is a count of the latest sequence numbers, which are remembered
+// to tell duplicates from reordered messages.
+const sequenceWindow = 64
+
+// LossHandler is called, when loss ratio of the upstream port exceeds the threshold.
+type LossHandler[T any] func(node NodeConfig[T], stats LossStats)
+
+// LossStats is a counters of messages received from one output port of the upstream node
+// by the input port from the topic.
+type LossStats struct {
+	Input        string `json:"input"`
+	Topic        string `json:"topic"`
+	SourceNode   string `json:"source_node"`
+	Port         string `json:"port"`
+	LastSequence uint64 `json:"last_sequence"`
+	Received     uint64 `json:"received"`
+	Lost         uint64 `json:"lost"`
+	Duplicates   uint64 `json:"duplicates"`
+	Reordered    uint64 `json:"reordered"`
+	// Restarts is a count of times, when upstream started sequence from the beginning.
+	Restarts uint64 `json:"restarts"`
+}
+
+// LossRatio returns part of messages, which were sent by upstream, but not received.
+func (s LossStats) LossRatio() float64 {
+	total := s.Received + s.Lost
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Lost) / float64(total)
+}
+
+type sequenceStream struct {
+	stats LossStats
+	// epoch is a stream id of the upstream node instance, see MetadataStream.
+	epoch string
+	// first is a sequence of the first message received since the stream (re)start.
+	first uint64
+	// seen is a bitmap of received sequences, where bit i is set for LastSequence - i.
+	seen uint64
+}
+
+// observe registers received sequence number of the upstream stream epoch,
+// it returns true when new loss was detected.
+func (s *sequenceStream) observe(seq uint64, epoch string) bool {
+	last := s.stats.LastSequence
+
+	switch {
+	case s.stats.Received == 0:
+		s.restart(seq, epoch)
+
+	case epoch != s.epoch:
+		// upstream node instance was replaced, its sequence is a new one
+		s.stats.Restarts++
+		s.restart(seq, epoch)
+
+	case seq > last:
+		gap := seq - last
+		s.stats.Lost += gap - 1
+		s.stats.LastSequence = seq
+		s.seen = shiftSeen(s.seen, gap) | 1
+		s.stats.Received++
+
+		return gap > 1
+
+	case last-seq >= sequenceWindow, seq == 1 && last > 1 && s.seen&(1<<(last-seq)) != 0:
+		// sequence far behind the window or repeated beginning of the sequence:
+		// upstream was restarted and started numbering from the beginning
+		s.stats.Restarts++
+		s.restart(seq, epoch)
+
+	case s.seen&(1<<(last-seq)) != 0:
+		s.stats.Duplicates++
+		return false
+
+	default:
+		s.seen |= 1 << (last - seq)
+		s.stats.Reordered++
+
+		// messages before the first received one were never counted as lost
+		if seq > s.first && s.stats.Lost > 0 {
+			s.stats.Lost--
+		}
+	}
+
+	s.stats.Received++
+
+	return false
+}
+
+// restart starts tracking of the stream from the sequence.
+func (s *sequenceStream) restart(seq uint64, epoch string) {
+	s.stats.LastSequence = seq
+	s.epoch = epoch
+	s.first = seq
+	s.seen = 1
+}
+
+func shiftSeen(seen, shift uint64) uint64 {
+	if shift >= sequenceWindow {
+		return 0
+	}
+
+	return seen << shift
+}
+
+type sequenceKey struct {
+	input  string
+	topic  string
+	source string
+	port   string
+}
+
+type sequenceTracker struct {
+	mu      sync.Mutex
+	streams map[sequenceKey]*sequenceStream
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{
+		mu:      sync.Mutex{},
+		streams: make(map[sequenceKey]*sequenceStream),
+	}
+}
+
+// observe registers envelope of the message received by the input port from the topic.
+// It returns stats of the upstream port and true, when new loss was detected.
+func (t *sequenceTracker) observe(input, topic string, env Envelope) (LossStats, bool) {
+	if env.SourceNode == "" || env.Sequence == 0 {
+		return LossStats{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := sequenceKey{input: input, topic: topic, source: env.SourceNode, port: env.Port}
+
+	stream, ok := t.streams[key]
+	if !ok {
+		stream = &sequenceStream{
+			stats: LossStats{Input: input, Topic: topic, SourceNode: env.SourceNode, Port: env.Port},
+			epoch: "",
+			first: 0,
+			seen:  0,
+		}
+		t.streams[key] = stream
+	}
+
+	lost := stream.observe(env.Sequence, env.Stream)
+
+	return stream.stats, lost
+}
+
+func (t *sequenceTracker) stats() []LossStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make([]LossStats, 0, len(t.streams))
+	for _, stream := range t.streams {
+		stats = append(stats, stream.stats)
+	}
+
+	slices.SortFunc(stats, func(a, b LossStats) int {
+		return cmp.Or(
+			cmp.Compare(a.Input, b.Input),
+			cmp.Compare(a.Topic, b.Topic),
+			cmp.Compare(a.SourceNode, b.SourceNode),
+			cmp.Compare(a.Port, b.Port),
+		)
+	})
+
+	return stats
+}
+
+// InputLoss returns loss counters of all upstream ports, the node received messages from,
+// per input port and topic.
+func (n *Node[T]) InputLoss() []LossStats {
+	return n.inputSequences.stats()
+}
+
+// observeSequence tracks sequence of the message received by the input port from the topic
+// and calls loss handler, when loss ratio of the upstream port exceeds the threshold.
+func (n *Node[T]) observeSequence(port, topic string, msg *message.Message) {
+	stats, lost := n.inputSequences.observe(port, topic, EnvelopeFromMessage(msg))
+	if !lost || n.onLoss == nil {
+		return
+	}
+
+	if stats.LossRatio() > n.lossThreshold {
+		_ = n.invoke(n.ctx, nodeEvent{name: nodeEventLoss, port: ""}, nil, func(_ context.Context) error {
+			n.onLoss(n.config, stats)
+			return nil
+		})
+	}
+}
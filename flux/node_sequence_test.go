@@ -0,0 +1,179 @@
+package flux
+
+import "testing"
+
+func TestSequenceStreamObserve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		sequences []uint64
+		want      LossStats
+		// wantLoss is true, when any observed sequence reported new loss
+		wantLoss bool
+	}{
+		{
+			name:      "in order",
+			sequences: []uint64{1, 2, 3, 4},
+			want:      LossStats{LastSequence: 4, Received: 4},
+			wantLoss:  false,
+		},
+		{
+			name:      "gap",
+			sequences: []uint64{1, 2, 5},
+			want:      LossStats{LastSequence: 5, Received: 3, Lost: 2},
+			wantLoss:  true,
+		},
+		{
+			name:      "late message is not lost",
+			sequences: []uint64{1, 3, 2},
+			want:      LossStats{LastSequence: 3, Received: 3, Reordered: 1},
+			wantLoss:  true,
+		},
+		{
+			name:      "duplicate",
+			sequences: []uint64{1, 2, 3, 3, 2},
+			want:      LossStats{LastSequence: 3, Received: 3, Duplicates: 2},
+			wantLoss:  false,
+		},
+		{
+			name:      "message before the first received one",
+			sequences: []uint64{5, 7, 3},
+			want:      LossStats{LastSequence: 7, Received: 3, Lost: 1, Reordered: 1},
+			wantLoss:  true,
+		},
+		{
+			name:      "restart from the beginning",
+			sequences: []uint64{1, 2, 3, 1, 2},
+			want:      LossStats{LastSequence: 2, Received: 5, Restarts: 1},
+			wantLoss:  false,
+		},
+		{
+			name:      "restart with lost first message",
+			sequences: []uint64{100, 101, 2, 3},
+			want:      LossStats{LastSequence: 3, Received: 4, Restarts: 1},
+			wantLoss:  false,
+		},
+		{
+			name:      "restart does not decrease lost",
+			sequences: []uint64{100, 102, 5},
+			want:      LossStats{LastSequence: 5, Received: 3, Lost: 1, Restarts: 1},
+			wantLoss:  true,
+		},
+		{
+			name:      "gap larger than window",
+			sequences: []uint64{1, 200, 150},
+			want:      LossStats{LastSequence: 200, Received: 3, Lost: 197, Reordered: 1},
+			wantLoss:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream := &sequenceStream{stats: LossStats{}, epoch: "", first: 0, seen: 0}
+
+			var loss bool
+			for _, seq := range tt.sequences {
+				loss = stream.observe(seq, "") || loss
+			}
+
+			if stream.stats != tt.want {
+				t.Errorf("stats = %+v, want %+v", stream.stats, tt.want)
+			}
+
+			if loss != tt.wantLoss {
+				t.Errorf("loss = %v, want %v", loss, tt.wantLoss)
+			}
+		})
+	}
+}
+
+func TestSequenceTrackerIgnoresUnsequenced(t *testing.T) {
+	t.Parallel()
+
+	tracker := newSequenceTracker()
+
+	if _, lost := tracker.observe("in", "t", Envelope{SourceNode: "", Sequence: 5}); lost {
+		t.Error("message without source is tracked")
+	}
+
+	if _, lost := tracker.observe("in", "t", Envelope{SourceNode: "camera", Sequence: 0}); lost {
+		t.Error("message without sequence is tracked")
+	}
+
+	if stats := tracker.stats(); len(stats) != 0 {
+		t.Errorf("stats = %+v, want none", stats)
+	}
+}
+
+func TestSequenceStreamEpoch(t *testing.T) {
+	t.Parallel()
+
+	type received struct {
+		seq   uint64
+		epoch string
+	}
+
+	tests := []struct {
+		name     string
+		received []received
+		want     LossStats
+	}{
+		{
+			name:     "same epoch",
+			received: []received{{1, "a"}, {2, "a"}, {2, "a"}},
+			want:     LossStats{LastSequence: 2, Received: 2, Duplicates: 1},
+		},
+		{
+			name:     "restart inside the window without the first message",
+			received: []received{{9, "a"}, {10, "a"}, {2, "b"}, {3, "b"}},
+			want:     LossStats{LastSequence: 3, Received: 4, Restarts: 1},
+		},
+		{
+			name:     "restart with higher sequence",
+			received: []received{{1, "a"}, {2, "a"}, {5, "b"}},
+			want:     LossStats{LastSequence: 5, Received: 3, Restarts: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream := &sequenceStream{stats: LossStats{}, epoch: "", first: 0, seen: 0}
+			for _, r := range tt.received {
+				stream.observe(r.seq, r.epoch)
+			}
+
+			if stream.stats != tt.want {
+				t.Errorf("stats = %+v, want %+v", stream.stats, tt.want)
+			}
+		})
+	}
+}
+
+func TestSequenceTrackerInputs(t *testing.T) {
+	t.Parallel()
+
+	tracker := newSequenceTracker()
+
+	// two inputs of the node consume the same topic, each message is received by both
+	for _, seq := range []uint64{1, 2, 3} {
+		env := Envelope{SourceNode: "camera", Port: "frame", Sequence: seq, Stream: "a"}
+		tracker.observe("left", "t", env)
+		tracker.observe("right", "t", env)
+	}
+
+	stats := tracker.stats()
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v, want one per input", stats)
+	}
+
+	for _, s := range stats {
+		if s.Received != 3 || s.Duplicates != 0 || s.Topic != "t" {
+			t.Errorf("stats = %+v, want 3 received without duplicates", s)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package flux
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+
+	"github.com/flux-agi/flux_go/fluxbag"
+)
+
+// EnvBagDir is an env of the directory, where bag files are recorded by default.
+const EnvBagDir = "FLUX_BAG_DIR"
+
+// RecordMessage is a payload of service.<id>.record control topic.
+type RecordMessage struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Recorder returns recorder of messages received and published by the service.
+func (s *Service[T]) Recorder() *fluxbag.Recorder {
+	return s.recorder
+}
+
+// RegisterRecordHandler registers handler of service.<id>.record topic, which starts and stops recording.
+func (s *Service[T]) RegisterRecordHandler(router *message.Router) {
+	router.AddNoPublisherHandler(
+		"flux.record",
+		s.topics.Record(),
+		s.Sub(),
+		s.handleRecord,
+	)
+}
+
+func (s *Service[T]) handleRecord(msg *message.Message) error {
+	var payload RecordMessage
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("flux: failed to unmarshal payload: %w", err)
+	}
+
+	if !payload.Enabled {
+		if err := s.recorder.Stop(); err != nil {
+			return fmt.Errorf("flux: failed to stop recording: %w", err)
+		}
+
+		s.logger.Info("recording stopped")
+
+		return nil
+	}
+
+	if err := s.recorder.Start(); err != nil {
+		return fmt.Errorf("flux: failed to start recording: %w", err)
+	}
+
+	s.logger.Info("recording started", slog.String("dir", s.recording.Dir))
+
+	return nil
+}
+
+func defaultRecorderConfig(serviceID string) fluxbag.RecorderConfig {
+	return fluxbag.RecorderConfig{
+		Dir:           cmp.Or(os.Getenv(EnvBagDir), "bags"),
+		Prefix:        serviceID,
+		MaxFileSize:   fluxbag.DefaultMaxFileSize,
+		MaxFiles:      0,
+		FlushInterval: fluxbag.DefaultFlushInterval,
+	}
+}
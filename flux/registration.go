@@ -0,0 +1,206 @@
+package flux
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// modulePath is a path of the SDK module, its version is read from the build info.
+const modulePath = "github.com/flux-agi/flux_go"
+
+// PortSpec is a declared port of the node type.
+type PortSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Schema is a JSON schema of the port payload, optional, see SchemaOf.
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// NodeType is a declared node type, which the service is able to run.
+type NodeType struct {
+	Type        string     `json:"type"`
+	Description string     `json:"description,omitempty"`
+	Inputs      []PortSpec `json:"inputs"`
+	Outputs     []PortSpec `json:"outputs"`
+	// Settings is a JSON schema of settings of the node type.
+	// When it is empty, schema of the service settings type is announced.
+	Settings json.RawMessage `json:"settings,omitempty"`
+}
+
+// Module is a Go module, which the service binary is built from.
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+// BuildInfo is a Go build info of the service binary.
+type BuildInfo struct {
+	GoVersion string `json:"go_version"`
+	Main      Module `json:"main"`
+	// Settings are build settings, e.g. vcs.revision and vcs.modified.
+	Settings map[string]string `json:"settings,omitempty"`
+	Deps     []Module          `json:"deps,omitempty"`
+}
+
+// Registration is a capability announcement of the service.
+// It is published into service.<id>.registration after connecting
+// and every time the manager requests it in service.<id>.request_registration.
+type Registration struct {
+	Service    string     `json:"service"`
+	SDKVersion string     `json:"sdk_version"`
+	Build      *BuildInfo `json:"build,omitempty"`
+	NodeTypes  []NodeType `json:"node_types"`
+	// SettingsSchema is a JSON schema of settings of the service nodes.
+	SettingsSchema json.RawMessage `json:"settings_schema"`
+	Timestamp      time.Time       `json:"timestamp"`
+}
+
+// DeclareNodeType declares node type, which is announced to the manager in the registration.
+// Declaring the same type again replaces it.
+func (n *NodeHandlers[T]) DeclareNodeType(nodeType NodeType) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	index := slices.IndexFunc(n.nodeTypes, func(t NodeType) bool { return t.Type == nodeType.Type })
+	if index >= 0 {
+		n.nodeTypes[index] = nodeType
+		return
+	}
+
+	n.nodeTypes = append(n.nodeTypes, nodeType)
+}
+
+// NodeTypes returns declared node types.
+func (n *NodeHandlers[T]) NodeTypes() []NodeType {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return slices.Clone(n.nodeTypes)
+}
+
+// DeclareNodeType declares node type, which is announced to the manager in the registration.
+func (s *Service[T]) DeclareNodeType(nodeType NodeType) {
+	s.nodeHandlers.DeclareNodeType(nodeType)
+}
+
+// Registration returns current capability announcement of the service.
+func (s *Service[T]) Registration() Registration {
+	settings := SchemaOf[T]()
+
+	handled := s.nodeHandlers.handledPorts()
+
+	nodeTypes := s.nodeHandlers.NodeTypes()
+	for i := range nodeTypes {
+		nodeTypes[i].Inputs = inputSpecs(handled, nodeTypes[i].Inputs)
+
+		if len(nodeTypes[i].Settings) == 0 {
+			nodeTypes[i].Settings = settings
+		}
+	}
+
+	build := readBuildInfo()
+
+	return Registration{
+		Service:        s.serviceID,
+		SDKVersion:     sdkVersion(build),
+		Build:          build,
+		NodeTypes:      nodeTypes,
+		SettingsSchema: settings,
+		Timestamp:      time.Now(),
+	}
+}
+
+// inputSpecs returns declared input ports of the node type, which have registered handlers.
+// Declared ports without handlers are not announced, because they never receive messages.
+func inputSpecs(handled []string, declared []PortSpec) []PortSpec {
+	inputs := make([]PortSpec, 0, len(declared))
+
+	for _, spec := range declared {
+		if slices.Contains(handled, spec.Name) {
+			inputs = append(inputs, spec)
+		}
+	}
+
+	return inputs
+}
+
+// publishRegistration publishes capability announcement of the service.
+func (s *Service[T]) publishRegistration() error {
+	data, err := json.Marshal(s.Registration())
+	if err != nil {
+		return fmt.Errorf("failed to marshal registration: %w", err)
+	}
+
+	err = s.Pub().Publish(s.topics.Registration(), message.NewMessage(watermill.NewUUID(), data))
+	if err != nil {
+		return fmt.Errorf("failed to publish registration: %w", err)
+	}
+
+	return nil
+}
+
+// handleRegistrationRequests answers registration requests of the manager, until requests are closed.
+func (s *Service[T]) handleRegistrationRequests(requests <-chan *message.Message) {
+	for msg := range requests {
+		if err := s.publishRegistration(); err != nil {
+			s.logger.Error("failed to answer registration request", slog.String("err", err.Error()))
+		}
+
+		msg.Ack()
+	}
+}
+
+func readBuildInfo() *BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	build := &BuildInfo{
+		GoVersion: info.GoVersion,
+		Main:      Module{Path: info.Main.Path, Version: info.Main.Version},
+		Settings:  make(map[string]string, len(info.Settings)),
+		Deps:      make([]Module, 0, len(info.Deps)),
+	}
+
+	for _, setting := range info.Settings {
+		build.Settings[setting.Key] = setting.Value
+	}
+
+	for _, dep := range info.Deps {
+		version := dep.Version
+		if dep.Replace != nil {
+			version = dep.Replace.Version
+		}
+
+		build.Deps = append(build.Deps, Module{Path: dep.Path, Version: version})
+	}
+
+	return build
+}
+
+// sdkVersion returns version of the SDK module, which the service is built with.
+func sdkVersion(build *BuildInfo) string {
+	if build == nil {
+		return "unknown"
+	}
+
+	if build.Main.Path == modulePath {
+		return build.Main.Version
+	}
+
+	for _, dep := range build.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+
+	return "unknown"
+}
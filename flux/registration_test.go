@@ -0,0 +1,91 @@
+package flux
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestInputSpecs(t *testing.T) {
+	t.Parallel()
+
+	schema := json.RawMessage(`{"type":"string"}`)
+
+	tests := []struct {
+		name     string
+		handled  []string
+		declared []PortSpec
+		want     []string
+	}{
+		{
+			name:     "type without inputs",
+			handled:  []string{"a", "b"},
+			declared: nil,
+			want:     []string{},
+		},
+		{
+			name:     "declared order",
+			handled:  []string{"a", "b"},
+			declared: []PortSpec{{Name: "b", Description: "", Schema: nil}, {Name: "a", Description: "", Schema: nil}},
+			want:     []string{"b", "a"},
+		},
+		{
+			name:     "declared without handler",
+			handled:  []string{"a"},
+			declared: []PortSpec{{Name: "a", Description: "", Schema: schema}, {Name: "c", Description: "", Schema: nil}},
+			want:     []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := inputSpecs(tt.handled, tt.declared)
+
+			names := make([]string, 0, len(got))
+			for _, spec := range got {
+				names = append(names, spec.Name)
+			}
+
+			if !slices.Equal(names, tt.want) {
+				t.Fatalf("inputs %v, want %v", names, tt.want)
+			}
+
+			for _, spec := range got {
+				index := slices.IndexFunc(tt.declared, func(d PortSpec) bool { return d.Name == spec.Name })
+				if index >= 0 && string(spec.Schema) != string(tt.declared[index].Schema) {
+					t.Fatalf("schema of %s is %s, want declared %s", spec.Name, spec.Schema, tt.declared[index].Schema)
+				}
+			}
+		})
+	}
+}
+
+func TestServiceRegistrationInputs(t *testing.T) {
+	t.Parallel()
+
+	s := &Service[any]{serviceID: "svc"}
+	s.DeclareNodeType(NodeType{Type: "camera", Inputs: nil, Outputs: []PortSpec{{Name: "frame"}}})
+	s.DeclareNodeType(NodeType{Type: "detector", Inputs: []PortSpec{{Name: "frame"}, {Name: "depth"}}})
+	s.nodeHandlers.OnSubscribeContext("frame", func(_ context.Context, _ NodeConfig[any], _ []byte) error { return nil })
+
+	want := map[string][]string{"camera": {}, "detector": {"frame"}}
+
+	nodeTypes := s.Registration().NodeTypes
+	if len(nodeTypes) != len(want) {
+		t.Fatalf("%d node types, want %d", len(nodeTypes), len(want))
+	}
+
+	for _, nodeType := range nodeTypes {
+		names := make([]string, 0, len(nodeType.Inputs))
+		for _, spec := range nodeType.Inputs {
+			names = append(names, spec.Name)
+		}
+
+		if !slices.Equal(names, want[nodeType.Type]) {
+			t.Errorf("inputs of %s %v, want %v", nodeType.Type, names, want[nodeType.Type])
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package flux
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+	timeType       = reflect.TypeFor[time.Time]()
+	durationType   = reflect.TypeFor[time.Duration]()
+)
+
+// SchemaOf returns JSON schema of values of type V, as they are encoded by encoding/json.
+// Field descriptions are read from the `description` struct tag.
+func SchemaOf[V any]() json.RawMessage {
+	data, err := json.Marshal(schemaOf(reflect.TypeFor[V](), make(map[reflect.Type]bool)))
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
+
+// schemaOf builds schema of the type, visiting holds struct types being built to stop on recursive types.
+//
+//nolint:cyclop
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case rawMessageType:
+		return map[string]any{}
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "integer", "description": "nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), visiting)}
+
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), visiting)}
+
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]any{"type": "object"}
+		}
+
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := make(map[string]any)
+		required := make([]string, 0)
+		structFields(t, visiting, properties, &required)
+
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+
+		return schema
+
+	default:
+		// interfaces accept any value, channels and functions are not encoded
+		return map[string]any{}
+	}
+}
+
+// structFields adds fields of the struct into properties, fields of embedded structs are promoted.
+func structFields(t reflect.Type, visiting map[reflect.Type]bool, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				structFields(embedded, visiting, properties, required)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := schemaOf(field.Type, visiting)
+		if description := field.Tag.Get("description"); description != "" {
+			schema["description"] = description
+		}
+
+		properties[name] = schema
+
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") &&
+			field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
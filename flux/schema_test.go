@@ -0,0 +1,56 @@
+package flux
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type schemaEmbedded struct {
+	Embedded string `json:"embedded"`
+}
+
+type schemaNode struct {
+	Next *schemaNode `json:"next"`
+}
+
+type schemaSettings struct {
+	schemaEmbedded
+
+	Threshold float64         `json:"threshold" description:"detection threshold"`
+	Labels    []string        `json:"labels,omitempty"`
+	Image     []byte          `json:"image"`
+	Extra     map[string]int  `json:"extra,omitempty"`
+	Timeout   time.Duration   `json:"timeout"`
+	At        time.Time       `json:"at"`
+	Raw       json.RawMessage `json:"raw,omitempty"`
+	Optional  *int            `json:"optional"`
+	Tree      schemaNode      `json:"tree"`
+	Skipped   string          `json:"-"`
+	Untagged  bool
+	hidden    string //nolint:unused
+}
+
+func TestSchemaOf(t *testing.T) {
+	t.Parallel()
+
+	got := string(SchemaOf[schemaSettings]())
+
+	want := `{"properties":{` +
+		`"Untagged":{"type":"boolean"},` +
+		`"at":{"format":"date-time","type":"string"},` +
+		`"embedded":{"type":"string"},` +
+		`"extra":{"additionalProperties":{"type":"integer"},"type":"object"},` +
+		`"image":{"contentEncoding":"base64","type":"string"},` +
+		`"labels":{"items":{"type":"string"},"type":"array"},` +
+		`"optional":{"type":"integer"},` +
+		`"raw":{},` +
+		`"threshold":{"description":"detection threshold","type":"number"},` +
+		`"timeout":{"description":"nanoseconds","type":"integer"},` +
+		`"tree":{"properties":{"next":{"type":"object"}},"type":"object"}` +
+		`},"required":["embedded","threshold","image","timeout","at","tree","Untagged"],"type":"object"}`
+
+	if got != want {
+		t.Fatalf("schema\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,273 @@
+package flux
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/nats-io/nkeys"
+
+	"github.com/flux-agi/flux_go/fluxmq"
+)
+
+// MetadataEncryption is a metadata key of the payload encryption algorithm.
+const MetadataEncryption = "flux_encryption"
+
+const EncryptionAES256GCM = "aes-256-gcm"
+
+var (
+	ErrNoPayloadCipher      = errors.New("flux: payload encryption key is not set")
+	ErrUnencryptedPayload   = errors.New("flux: payload of encrypted port is not encrypted")
+	ErrUnknownEncryption    = errors.New("flux: unknown payload encryption")
+	ErrInvalidEncryptionKey = errors.New("flux: encryption key must be 32 bytes")
+)
+
+// SigningOptions configures ed25519 signing of messages between nodes and the manager.
+type SigningOptions struct {
+	// KeyPair signs messages published by the service.
+	KeyPair nkeys.KeyPair
+	// TrustedKeys are public keys of the manager and other services.
+	TrustedKeys []string
+	// SignData signs and verifies messages of node ports, otherwise only control topics are covered.
+	SignData bool
+	// MaxAge rejects messages signed earlier, zero means fluxmq.DefaultSignatureMaxAge, negative disables the check.
+	MaxAge time.Duration
+}
+
+func (o SigningOptions) config() fluxmq.SigningConfig {
+	match := IsControlTopic
+	if o.SignData {
+		match = nil
+	}
+
+	return fluxmq.SigningConfig{
+		KeyPair:     o.KeyPair,
+		TrustedKeys: o.TrustedKeys,
+		Match:       match,
+		MaxAge:      o.MaxAge,
+	}
+}
+
+// IsControlTopic reports whether the topic controls services and nodes,
+// e.g. node/<id>/event/stop, node.<id>.set_settings or service.<id>.* topics.
+func IsControlTopic(topic string) bool {
+	switch {
+	case strings.HasPrefix(topic, "service.") || strings.HasPrefix(topic, "service/"):
+		return true
+	case strings.HasPrefix(topic, "node."):
+		return true
+	case strings.HasPrefix(topic, "node/") && strings.Contains(topic, "/event/"):
+		return true
+	case topic == "ide.status":
+		return true
+	default:
+		return false
+	}
+}
+
+// signPublisher wraps publisher with signing, when signing is enabled.
+func (s *Service[T]) signPublisher(pub message.Publisher, options *RunOptions) (message.Publisher, error) {
+	if options.signing == nil {
+		return pub, nil
+	}
+
+	signing, err := fluxmq.NewSigningPublisher(pub, options.signing.config())
+	if err != nil {
+		return nil, fmt.Errorf("could not create signing publisher: %w", err)
+	}
+
+	return signing, nil
+}
+
+// verifySubscriber wraps subscriber with signature verification, when signing is enabled.
+func (s *Service[T]) verifySubscriber(sub message.Subscriber, options *RunOptions) (message.Subscriber, error) {
+	if options.signing == nil {
+		return sub, nil
+	}
+
+	verifying, err := fluxmq.NewVerifyingSubscriber(sub, options.signing.config(), s.rejectMessage)
+	if err != nil {
+		return nil, fmt.Errorf("could not create verifying subscriber: %w", err)
+	}
+
+	return verifying, nil
+}
+
+func (s *Service[T]) rejectMessage(topic string, msg *message.Message, err error) {
+	s.metrics.Add(MetricRejectedMessages, 1, nil)
+	s.logger.Warn(
+		"rejected message with invalid signature",
+		slog.String("topic", topic),
+		slog.String("signer", msg.Metadata.Get(fluxmq.MetadataSigner)),
+		slog.String("err", err.Error()),
+	)
+}
+
+// PayloadCipher encrypts payloads of sensitive ports with AES-256-GCM.
+type PayloadCipher struct {
+	aead cipher.AEAD
+}
+
+// NewPayloadCipher creates cipher with 32 bytes key, which is shared by services exchanging encrypted payloads.
+func NewPayloadCipher(key []byte) (*PayloadCipher, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidEncryptionKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %w", err)
+	}
+
+	return &PayloadCipher{aead: aead}, nil
+}
+
+// Encrypt returns nonce followed by sealed payload, aad is authenticated, but not encrypted.
+func (c *PayloadCipher) Encrypt(payload, aad []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(payload)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, payload, aad), nil
+}
+
+func (c *PayloadCipher) Decrypt(payload, aad []byte) ([]byte, error) {
+	if len(payload) < c.aead.NonceSize() {
+		return nil, errors.New("flux: encrypted payload is too short")
+	}
+
+	nonce, sealed := payload[:c.aead.NonceSize()], payload[c.aead.NonceSize():]
+
+	data, err := c.aead.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt payload: %w", err)
+	}
+
+	return data, nil
+}
+
+// EncryptPort makes the port encrypted: output payloads are encrypted,
+// and unencrypted messages of the input port are rejected.
+func (n *NodeHandlers[T]) EncryptPort(port string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.encryptedPorts == nil {
+		n.encryptedPorts = make(map[string]bool)
+	}
+	n.encryptedPorts[port] = true
+}
+
+// initEncryption marks encrypted ports from handlers and port config.
+func (n *Node[T]) initEncryption(defaults map[string]bool) {
+	for port := range defaults {
+		n.encrypted[port] = true
+	}
+
+	for _, p := range append(n.config.Inputs, n.config.Outputs...) {
+		if p != nil && p.Encrypt {
+			n.encrypted[p.Alias] = true
+		}
+	}
+}
+
+// payloadAAD binds encrypted payload to its source node and port.
+func payloadAAD(msg *message.Message) []byte {
+	return []byte(msg.Metadata.Get(MetadataSourceNode) + "/" + msg.Metadata.Get(MetadataPort))
+}
+
+// encryptPayload encrypts payload of the encrypted output port.
+func (n *Node[T]) encryptPayload(port string, msg *message.Message) error {
+	if !n.encrypted[port] {
+		return nil
+	}
+
+	if n.cipher == nil {
+		return ErrNoPayloadCipher
+	}
+
+	payload, err := n.cipher.Encrypt(msg.Payload, payloadAAD(msg))
+	if err != nil {
+		return err
+	}
+
+	msg.Payload = payload
+	msg.Metadata.Set(MetadataEncryption, EncryptionAES256GCM)
+
+	return nil
+}
+
+type encryptedOriginalKey struct{}
+
+// encryptedOriginal is a received message of the encrypted port before decryption.
+type encryptedOriginal struct {
+	payload  []byte
+	metadata message.Metadata
+}
+
+// keepEncryptedOriginal remembers encrypted payload and metadata of the received message in its context,
+// so dead letters and error records do not expose the decrypted payload.
+func keepEncryptedOriginal(msg *message.Message) {
+	if msg.Metadata.Get(MetadataEncryption) == "" {
+		return
+	}
+
+	original := encryptedOriginal{payload: msg.Payload, metadata: maps.Clone(msg.Metadata)}
+	msg.SetContext(context.WithValue(msg.Context(), encryptedOriginalKey{}, original))
+}
+
+// encryptedOriginalOf returns the message as it was received, when it arrived encrypted.
+func encryptedOriginalOf(msg *message.Message) (encryptedOriginal, bool) {
+	original, ok := msg.Context().Value(encryptedOriginalKey{}).(encryptedOriginal)
+	return original, ok
+}
+
+// receivedEncrypted reports whether the message arrived encrypted.
+func receivedEncrypted(msg *message.Message) bool {
+	_, ok := encryptedOriginalOf(msg)
+	return ok
+}
+
+// decryptPayload decrypts payload of the received message.
+// Unencrypted messages of the encrypted input port are rejected.
+func (n *Node[T]) decryptPayload(port string, msg *message.Message) error {
+	switch msg.Metadata.Get(MetadataEncryption) {
+	case "":
+		if n.encrypted[port] {
+			return ErrUnencryptedPayload
+		}
+
+		return nil
+
+	case EncryptionAES256GCM:
+		if n.cipher == nil {
+			return ErrNoPayloadCipher
+		}
+
+		payload, err := n.cipher.Decrypt(msg.Payload, payloadAAD(msg))
+		if err != nil {
+			return err
+		}
+
+		msg.Payload = payload
+		delete(msg.Metadata, MetadataEncryption)
+
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownEncryption, msg.Metadata.Get(MetadataEncryption))
+	}
+}
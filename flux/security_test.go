@@ -0,0 +1,71 @@
+package flux
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPayloadCipher(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{7}, 32)
+
+	if _, err := NewPayloadCipher(key[:16]); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Fatalf("short key: error %v, want %v", err, ErrInvalidEncryptionKey)
+	}
+
+	cipher, err := NewPayloadCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewPayloadCipher(bytes.Repeat([]byte{8}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte(`{"pose":[1,2,3]}`)
+	aad := []byte("camera/image")
+
+	sealed, err := cipher.Encrypt(payload, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(sealed, payload) {
+		t.Fatal("payload is not encrypted")
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		cipher  *PayloadCipher
+		payload []byte
+		aad     []byte
+		wantErr bool
+	}{
+		{name: "valid", cipher: cipher, payload: sealed, aad: aad, wantErr: false},
+		{name: "other key", cipher: other, payload: sealed, aad: aad, wantErr: true},
+		{name: "other port", cipher: cipher, payload: sealed, aad: []byte("camera/depth"), wantErr: true},
+		{name: "tampered", cipher: cipher, payload: tampered, aad: aad, wantErr: true},
+		{name: "too short", cipher: cipher, payload: sealed[:4], aad: aad, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.cipher.Decrypt(tt.payload, tt.aad)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !bytes.Equal(got, payload) {
+				t.Fatalf("decrypted %q, want %q", got, payload)
+			}
+		})
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
 
+	"github.com/flux-agi/flux_go/fluxbag"
 	"github.com/flux-agi/flux_go/fluxmq"
+	"github.com/flux-agi/flux_go/fluxtrace"
 )
 
 type Service[T any] struct {
@@ -23,6 +28,9 @@ type Service[T any] struct {
 	sub             message.Subscriber
 	call            fluxmq.Caller
 	connectionMutex *sync.RWMutex
+	// pubConn and subConn report NATS connections of pub and sub, nil when factories do not support it
+	pubConn connectionChecker
+	subConn connectionChecker
 
 	// onConnect func will be called in Run method after pub & sub creation
 	onConnect func() error
@@ -38,8 +46,31 @@ type Service[T any] struct {
 	topics *ServiceTopics
 	status *AtomicValue[ServiceStatus]
 	state  *State
-
-	nodes        []Node[T]
+	tracer *fluxtrace.Tracer
+
+	logForwarder *LogForwarder
+	deadLetters  *deadLetterStore
+	// legacyPortTopics makes nodes publish into node/<id>/<port> topics as well.
+	legacyPortTopics bool
+	validationMode   ValidationMode
+	// Chunking decorators of pub and sub, nil when chunking is disabled
+	chunkPub *fluxmq.ChunkingPublisher
+	chunkSub *fluxmq.ChunkingSubscriber
+	cipher   *PayloadCipher
+	// maxDecodedSize is a maximal size of decompressed input payloads of nodes
+	maxDecodedSize int
+	// recorder writes traffic of the service into bag files, when it is started
+	recorder       *fluxbag.Recorder
+	recording      fluxbag.RecorderConfig
+	recordingStart bool
+
+	// configApplied is set, when nodes were created from the received config.
+	configApplied atomic.Bool
+
+	metrics *Metrics
+
+	nodes        []*Node[T]
+	nodesMu      sync.RWMutex
 	nodeHandlers NodeHandlers[T]
 }
 
@@ -55,27 +86,85 @@ func NewService[T any](opts ...ServiceOption) *Service[T] {
 		sub:    nil,
 		call:   nil,
 		state:  NewState(),
+		tracer: nil,
+
+		logForwarding:        LogForwarderOptions{},
+		disableLogForwarding: false,
+
+		deadLetterCapacity: 0,
+		legacyPortTopics:   false,
+		validationMode:     ValidationModeWarn,
+		cipher:             nil,
+		maxDecodedSize:     DefaultMaxDecodedSize,
+		recording:          nil,
 	}
 
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	return &Service[T]{
-		logger:          options.logger,
-		pub:             options.pub,
-		sub:             options.sub,
-		call:            options.call,
-		connectionMutex: new(sync.RWMutex),
-		serviceID:       serviceID,
-		onConnect:       nil,
-		onReady:         nil,
-		onIDEStatus:     nil,
-		topics:          NewTopics(serviceID),
-		status:          NewAtomicValue(ServiceStatusStarting),
-		state:           options.state,
-		nodes:           make([]Node[T], 0),
+	if options.tracer == nil {
+		options.tracer = fluxtrace.Default()
+	}
+
+	if options.maxDecodedSize <= 0 {
+		options.maxDecodedSize = DefaultMaxDecodedSize
 	}
+
+	if options.logger == nil {
+		options.logger = NewDefaultLogger(serviceID)
+	}
+
+	var forwarder *LogForwarder
+	if !options.disableLogForwarding {
+		forwarder = NewLogForwarder(serviceID, options.logForwarding)
+		options.logger = slog.New(teeHandler{options.logger.Handler(), forwarder})
+	}
+
+	service := &Service[T]{
+		logger:           options.logger,
+		pub:              options.pub,
+		sub:              options.sub,
+		call:             options.call,
+		connectionMutex:  new(sync.RWMutex),
+		pubConn:          nil,
+		subConn:          nil,
+		serviceID:        serviceID,
+		onConnect:        nil,
+		onReady:          nil,
+		onIDEStatus:      nil,
+		topics:           NewTopics(serviceID),
+		status:           NewAtomicValue(ServiceStatusStarting),
+		state:            options.state,
+		tracer:           options.tracer,
+		logForwarder:     forwarder,
+		deadLetters:      nil,
+		legacyPortTopics: options.legacyPortTopics,
+		validationMode:   options.validationMode,
+		chunkPub:         nil,
+		chunkSub:         nil,
+		cipher:           options.cipher,
+		maxDecodedSize:   options.maxDecodedSize,
+		recorder:         nil,
+		recording:        defaultRecorderConfig(serviceID),
+		recordingStart:   options.recording != nil,
+		metrics:          NewMetrics(),
+		nodes:            make([]*Node[T], 0),
+	}
+
+	if options.recording != nil {
+		service.recording = *options.recording
+	}
+
+	service.recorder = fluxbag.NewRecorder(service.recording)
+
+	if options.deadLetterCapacity > 0 {
+		service.deadLetters = newDeadLetterStore(options.deadLetterCapacity)
+	}
+
+	service.metrics.AddCollector(service.collectNodeMetrics)
+
+	return service
 }
 
 //nolint:cyclop
@@ -87,19 +176,24 @@ func (s *Service[T]) Run(ctx context.Context, opts ...ConnectOption) error {
 	url := cmp.Or(os.Getenv("NATS_URL"), DefaultNatsURL)
 
 	options := &RunOptions{
-		watermillLogger: watermill.NopLogger{},
+		watermillLogger: NewDefaultWatermillLogger(s.logger),
 		// TODO: use same connection for pub, sub and call.
 		pubFactory:  DefaultPublisherFactory(url),
 		subFactory:  DefaultSubscriberFactory(url),
-		callFactory: DefaultCallerFactory(url),
+		callFactory: TracedCallerFactory(url, s.tracer),
 
-		routerFactory: DefaultRouterFactory,
-		configTimeout: DefaultConfigWaitingTimeout,
+		routerFactory:   DefaultRouterFactory,
+		configTimeout:   DefaultConfigWaitingTimeout,
+		httpAddr:        os.Getenv("FLUX_HTTP_ADDR"),
+		metricsInterval: DefaultMetricsInterval,
 	}
 	for _, opt := range opts {
 		opt(options)
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	err := s.connect(options)
 	if err != nil {
 		return fmt.Errorf("failed to connect service: %w", err)
@@ -117,6 +211,37 @@ func (s *Service[T]) Run(ctx context.Context, opts ...ConnectOption) error {
 		return fmt.Errorf("failed to update status: %w", err)
 	}
 
+	err = s.publishRegistration()
+	if err != nil {
+		return err
+	}
+
+	if s.recordingStart {
+		if err := s.recorder.Start(); err != nil {
+			return fmt.Errorf("failed to start recording: %w", err)
+		}
+	}
+
+	defer func() {
+		if err := s.recorder.Stop(); err != nil {
+			s.logger.Error("failed to stop recording", slog.String("err", err.Error()))
+		}
+	}()
+
+	if options.httpAddr != "" {
+		if err := s.serveHTTP(ctx, options.httpAddr); err != nil {
+			return fmt.Errorf("failed to serve http: %w", err)
+		}
+	}
+
+	if options.metricsInterval > 0 {
+		go s.publishMetrics(ctx, options.metricsInterval)
+	}
+
+	if s.logForwarder != nil {
+		go s.logForwarder.Run(ctx, s.topics.Logs(), s.Pub)
+	}
+
 	err = s.run(ctx, options)
 	if err != nil {
 		return fmt.Errorf("failed to run service: %w", err)
@@ -135,11 +260,25 @@ func (s *Service[T]) run(ctx context.Context, options *RunOptions) error {
 		return fmt.Errorf("failed to create config subscriber: %w", err)
 	}
 
+	configSub, err = s.verifySubscriber(configSub, options)
+	if err != nil {
+		return err
+	}
+
 	configs, err := configSub.Subscribe(ctx, s.topics.ResponseConfig())
 	if err != nil {
 		return fmt.Errorf("failed to subscribe to configs: %w", err)
 	}
 
+	// registration is requested by the manager through the config subscriber,
+	// because it must be answered before config is received and across reconnects
+	registrationRequests, err := configSub.Subscribe(ctx, s.topics.RequestRegistration())
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to registration requests: %w", err)
+	}
+
+	go s.handleRegistrationRequests(registrationRequests)
+
 	err = s.requestConfig()
 	if err != nil {
 		return fmt.Errorf("failed to request config: %w", err)
@@ -148,13 +287,24 @@ func (s *Service[T]) run(ctx context.Context, options *RunOptions) error {
 	var router *message.Router
 
 	for msg := range configs {
-		slog.DebugContext(ctx, "new confing was received")
+		s.logger.DebugContext(ctx, "new config was received")
+		s.recorder.Record(fluxbag.DirectionIn, s.topics.ResponseConfig(), msg)
+
 		var config NodesConfig[T]
 		err := json.Unmarshal(msg.Payload, &config)
 		if err != nil {
 			return fmt.Errorf("failed to unmarshal config: %w", err)
 		}
 
+		report, err := s.validateConfig(config)
+		if err != nil {
+			// rejected config is reported, running nodes are kept
+			s.logger.ErrorContext(ctx, "config is rejected", slog.String("err", err.Error()))
+			msg.Ack()
+
+			continue
+		}
+
 		router, err = s.reinitRouter(router, options)
 		if err != nil {
 			return fmt.Errorf("failed to reinit router: %w", err)
@@ -167,20 +317,23 @@ func (s *Service[T]) run(ctx context.Context, options *RunOptions) error {
 			}
 		}
 
-		err = s.reloadNodes(ctx, &config, router)
+		s.configApplied.Store(false)
+
+		err = s.reloadNodes(ctx, &config, router, report)
 		if err != nil {
 			return fmt.Errorf("failed to reload nodes: %w", err)
 		}
 
+		s.configApplied.Store(true)
+
 		msg.Ack()
 
 		go func() {
 			err = router.Run(ctx)
 			if err != nil {
-				slog.ErrorContext(
+				s.logger.ErrorContext(
 					ctx,
 					"Failed to run router",
-					slog.String("service", s.serviceID),
 					slog.String("err", err.Error()),
 				)
 				cancel()
@@ -240,6 +393,27 @@ func (s *Service[T]) connect(options *RunOptions) error {
 		return fmt.Errorf("failed to create nats call: %w", err)
 	}
 
+	s.pubConn, _ = s.pub.(connectionChecker)
+	s.subConn, _ = s.sub.(connectionChecker)
+
+	if options.chunking != nil {
+		s.chunkPub = fluxmq.NewChunkingPublisher(s.pub, *options.chunking)
+		s.chunkSub = fluxmq.NewChunkingSubscriber(s.sub, *options.chunking)
+		s.pub, s.sub = s.chunkPub, s.chunkSub
+	}
+
+	s.pub = fluxbag.NewRecordingPublisher(s.pub, s.recorder)
+
+	s.pub, err = s.signPublisher(s.pub, options)
+	if err != nil {
+		return err
+	}
+
+	s.sub, err = s.verifySubscriber(s.sub, options)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -247,6 +421,12 @@ func (s *Service[T]) disconnect() error {
 	s.connectionMutex.Lock()
 	defer s.connectionMutex.Unlock()
 
+	// chunking counters restart with the new connection
+	collectChunkMetrics(s.metrics, s.chunkPub, s.chunkSub)
+	s.metrics.restartCollected(chunkMetrics, nil)
+	s.chunkPub, s.chunkSub = nil, nil
+	s.pubConn, s.subConn = nil, nil
+
 	if s.sub != nil {
 		err := s.sub.Close()
 		if err != nil {
@@ -285,8 +465,11 @@ func (s *Service[T]) requestConfig() error {
 
 func (s *Service[T]) initRouter(options *RunOptions) (*message.Router, error) {
 	router := options.routerFactory(options.watermillLogger)
+	router.AddMiddleware(s.recoverer, s.recorder.Middleware)
 	s.RegisterStatusHandler(router)
 	s.RegisterIDEStatusHandler(router)
+	s.RegisterLogLevelHandler(router)
+	s.RegisterRecordHandler(router)
 	router.AddPlugin(func(_ *message.Router) error {
 		return s.UpdateStatus(ServiceStatusReady)
 	})
@@ -294,14 +477,27 @@ func (s *Service[T]) initRouter(options *RunOptions) (*message.Router, error) {
 	return router, nil
 }
 
-func (s *Service[T]) reloadNodes(ctx context.Context, nodes *NodesConfig[T], router *message.Router) error {
-	for _, node := range s.nodes {
+// reloadNodes replaces running nodes with nodes of the validated config.
+func (s *Service[T]) reloadNodes(
+	ctx context.Context,
+	nodes *NodesConfig[T],
+	router *message.Router,
+	report ValidationReport,
+) error {
+	s.nodesMu.Lock()
+	previous := s.nodes
+	s.nodes = nil
+	s.nodesMu.Unlock()
+
+	s.retireNodeMetrics(previous, *nodes)
+
+	for _, node := range previous {
 		if err := node.Close(); err != nil {
 			s.logger.Error("failed to close node", slog.String("err", err.Error()))
 		}
 	}
 
-	resultNodes := make([]Node[T], 0)
+	resultNodes := make([]*Node[T], 0)
 	for _, nodeCfg := range *nodes {
 		node := NewNode[T](
 			ctx,
@@ -310,15 +506,47 @@ func (s *Service[T]) reloadNodes(ctx context.Context, nodes *NodesConfig[T], rou
 			s.pub,
 			nodeCfg,
 		)
+		node.tracer = s.tracer
+		node.logger = newNodeLogger(s.logger, nodeCfg.ID, nodeCfg.Name, nodeCfg.Type)
+		node.metrics = s.metrics
+		node.onError = func(record ErrorRecord) {
+			record.Service = s.serviceID
+			s.reportError(record)
+		}
+		node.onQuarantine = func(record QuarantineRecord) {
+			record.Service = s.serviceID
+			s.publishRecord(s.topics.Quarantine(), record)
+		}
+		if s.deadLetters != nil {
+			node.onDeadLetter = s.deadLetter
+		}
+		node.legacyTopics = s.legacyPortTopics
+		node.cipher = s.cipher
+		node.maxDecodedSize = s.maxDecodedSize
+
+		if slices.Contains(report.FailedNodes, nodeCfg.ID) {
+			// node with invalid ports is kept with error status and without handlers
+			node.status.Set(NodeStatusError)
+			resultNodes = append(resultNodes, node)
+			continue
+		}
 
 		if err := node.RegisterHandlers(&s.nodeHandlers); err != nil {
-			return fmt.Errorf("failed to register node handlers: %w", err)
+			var panicErr *PanicError
+			if !errors.As(err, &panicErr) {
+				return fmt.Errorf("failed to register node handlers: %w", err)
+			}
+
+			// panicked node is kept with error status, other nodes keep running
+			s.logger.Error("node panicked on registration", slog.String("node_id", nodeCfg.ID))
 		}
 
-		resultNodes = append(resultNodes, *node)
+		resultNodes = append(resultNodes, node)
 	}
 
+	s.nodesMu.Lock()
 	s.nodes = resultNodes
+	s.nodesMu.Unlock()
 
 	return nil
 }
@@ -352,6 +580,11 @@ func (s *Service[T]) Status() ServiceStatus {
 	return status
 }
 
+// Logger returns logger of the service.
+func (s *Service[T]) Logger() *slog.Logger {
+	return s.logger
+}
+
 func (s *Service[T]) State() *State {
 	return s.state
 }
@@ -378,6 +611,11 @@ func (s *Service[T]) Call() fluxmq.Caller {
 }
 
 func (s *Service[T]) PubToTopic(topic string, value any) error {
+	return s.PubToTopicContext(context.Background(), topic, value)
+}
+
+// PubToTopicContext publishes value into the topic and propagates trace context of ctx.
+func (s *Service[T]) PubToTopicContext(ctx context.Context, topic string, value any) error {
 	if topic == "" {
 		return fmt.Errorf("topic is empty")
 	}
@@ -387,5 +625,17 @@ func (s *Service[T]) PubToTopic(topic string, value any) error {
 		return fmt.Errorf("could not marshal payload: %w", err)
 	}
 
-	return s.pub.Publish(topic, message.NewMessage(watermill.NewUUID(), data))
+	ctx, span := s.tracer.Start(ctx, "flux.publish "+topic, fluxtrace.SpanKindProducer)
+	defer span.End()
+
+	span.SetAttribute("flux.service", s.serviceID)
+	span.SetAttribute("flux.topic", topic)
+
+	msg := message.NewMessage(watermill.NewUUID(), data)
+	fluxtrace.Inject(ctx, msg)
+
+	err = s.pub.Publish(topic, msg)
+	span.SetError(err)
+
+	return err
 }
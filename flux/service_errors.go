@@ -0,0 +1,185 @@
+package flux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// MaxErrorPayloadSample is a maximal size of payload sample in the error record.
+const MaxErrorPayloadSample = 256
+
+// ErrorHandler handles error records of the service.
+type ErrorHandler = func(record ErrorRecord) error
+
+// ErrorRecord is a structured report of node handler failure,
+// published into service.<id>.error topic.
+type ErrorRecord struct {
+	Service string `json:"service"`
+	NodeID  string `json:"node_id,omitempty"`
+	// Port is set for failures of subscribe handlers.
+	Port string `json:"port,omitempty"`
+	// Event is a kind of failed handler, e.g. subscribe, tick or settings.
+	Event string `json:"event"`
+	Error string `json:"error"`
+	// Chain is a list of wrapped errors, from the outermost to the innermost.
+	Chain []string `json:"chain"`
+	Panic bool     `json:"panic"`
+	// Stack is set for recovered panics.
+	Stack     string    `json:"stack,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// PayloadSample is a beginning of the handled message payload, it is left out for encrypted messages.
+	PayloadSample []byte `json:"payload_sample,omitempty"`
+	PayloadSize   int    `json:"payload_size"`
+}
+
+// PanicError is an error, which was recovered from panic.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func NewPanicError(value any) *PanicError {
+	return &PanicError{
+		Value: value,
+		Stack: debug.Stack(),
+	}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// newErrorRecord creates error record of the failed handler.
+// Payload of the message, which arrived encrypted, is not sampled.
+func newErrorRecord(serviceID, nodeID string, event nodeEvent, err error, payload []byte, encrypted bool) ErrorRecord {
+	record := ErrorRecord{
+		Service:       serviceID,
+		NodeID:        nodeID,
+		Port:          event.port,
+		Event:         event.name,
+		Error:         err.Error(),
+		Chain:         errorChain(err),
+		Panic:         false,
+		Stack:         "",
+		Timestamp:     time.Now().UTC(),
+		PayloadSample: nil,
+		PayloadSize:   len(payload),
+	}
+
+	if !encrypted {
+		record.PayloadSample = payload[:min(len(payload), MaxErrorPayloadSample)]
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		record.Panic = true
+		record.Stack = string(panicErr.Stack)
+	}
+
+	return record
+}
+
+// errorChain returns messages of all wrapped errors in depth-first order.
+func errorChain(err error) []string {
+	chain := make([]string, 0, 1)
+
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+
+		chain = append(chain, err.Error())
+
+		switch wrapped := err.(type) { //nolint:errorlint
+		case interface{ Unwrap() []error }:
+			for _, err := range wrapped.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(wrapped.Unwrap())
+		}
+	}
+
+	walk(err)
+
+	return chain
+}
+
+// reportError publishes error record into service.<id>.error topic.
+func (s *Service[T]) reportError(record ErrorRecord) {
+	s.publishRecord(s.topics.Errors(), record)
+}
+
+// publishRecord publishes json record for the manager, failures are only logged.
+func (s *Service[T]) publishRecord(topic string, record any) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		s.logger.Error("failed to marshal record", slog.String("topic", topic), slog.String("err", err.Error()))
+		return
+	}
+
+	pub := s.Pub()
+	if pub == nil {
+		return
+	}
+
+	if err := pub.Publish(topic, message.NewMessage(watermill.NewUUID(), data)); err != nil {
+		s.logger.Error("failed to publish record", slog.String("topic", topic), slog.String("err", err.Error()))
+	}
+}
+
+// nodeIDFromHandler returns id of the node, which registered router handler, see Node.handlerName.
+// It returns empty string for service handlers.
+func (s *Service[T]) nodeIDFromHandler(handler string) string {
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+
+	id := ""
+	for _, node := range s.nodes {
+		// the longest id wins, when ids of nodes are prefixes of each other
+		if strings.HasPrefix(handler, "flux.node."+node.config.ID+".") && len(node.config.ID) > len(id) {
+			id = node.config.ID
+		}
+	}
+
+	return id
+}
+
+// recoverer is a router middleware, which converts panics of handlers into errors and reports them.
+func (s *Service[T]) recoverer(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) (messages []*message.Message, err error) {
+		defer func() {
+			if value := recover(); value != nil {
+				panicErr := NewPanicError(value)
+				err = panicErr
+
+				handler := message.HandlerNameFromCtx(msg.Context())
+
+				s.reportError(newErrorRecord(
+					s.serviceID,
+					s.nodeIDFromHandler(handler),
+					nodeEvent{name: handler, port: ""},
+					panicErr,
+					msg.Payload,
+					receivedEncrypted(msg),
+				))
+			}
+		}()
+
+		return h(msg)
+	}
+}
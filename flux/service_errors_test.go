@@ -0,0 +1,54 @@
+package flux
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewErrorRecordPayloadSample(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+	long := bytes.Repeat([]byte{'x'}, MaxErrorPayloadSample+10)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		encrypted bool
+		want      []byte
+	}{
+		{name: "short payload", payload: []byte("abc"), encrypted: false, want: []byte("abc")},
+		{name: "long payload is cut", payload: long, encrypted: false, want: long[:MaxErrorPayloadSample]},
+		{name: "encrypted payload is not sampled", payload: []byte("secret"), encrypted: true, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			event := nodeEvent{name: nodeEventSubscribe, port: "in"}
+			record := newErrorRecord("service", "node", event, errHandler, tt.payload, tt.encrypted)
+
+			if !bytes.Equal(record.PayloadSample, tt.want) || record.PayloadSize != len(tt.payload) {
+				t.Fatalf("sample %q of %d bytes, want %q of %d bytes",
+					record.PayloadSample, record.PayloadSize, tt.want, len(tt.payload))
+			}
+		})
+	}
+}
+
+func TestReceivedEncrypted(t *testing.T) {
+	t.Parallel()
+
+	node := newEncryptedNode(t)
+
+	msg := encryptedMessage(t, node, []byte("secret"))
+	if err := node.decodePayload("in", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !receivedEncrypted(msg) {
+		t.Fatal("decrypted message is not marked as received encrypted")
+	}
+}
@@ -2,6 +2,7 @@ package flux
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"time"
@@ -100,12 +101,21 @@ func (s *Service[T]) OnServiceRestart(r *message.Router, handler message.NoPubli
 	)
 }
 
-func (s *Service[T]) OnServiceError(r *message.Router, handler message.NoPublishHandlerFunc) {
+// OnServiceError subscribes on error records of the service, which are published
+// when node handlers fail or panic.
+func (s *Service[T]) OnServiceError(r *message.Router, handler ErrorHandler) {
 	r.AddNoPublisherHandler(
 		"flux.on_error",
 		s.topics.Errors(),
 		s.sub,
-		handler,
+		func(msg *message.Message) error {
+			var record ErrorRecord
+			if err := json.Unmarshal(msg.Payload, &record); err != nil {
+				return fmt.Errorf("could not unmarshal error record: %w", err)
+			}
+
+			return handler(record)
+		},
 	)
 }
 
@@ -0,0 +1,124 @@
+package flux
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+
+	"github.com/flux-agi/flux_go/fluxmq"
+)
+
+// Metrics returns metrics registry of the service.
+// It can be used to register and update own metrics of the service.
+func (s *Service[T]) Metrics() *Metrics {
+	return s.metrics
+}
+
+// collectNodeMetrics updates gauges of the node queues, output limits, input losses and chunked transfers.
+func (s *Service[T]) collectNodeMetrics(m *Metrics) {
+	s.connectionMutex.RLock()
+	collectChunkMetrics(m, s.chunkPub, s.chunkSub)
+	s.connectionMutex.RUnlock()
+
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+
+	collectNodes(m, s.nodes)
+}
+
+func collectNodes[T any](m *Metrics, nodes []*Node[T]) {
+	for _, node := range nodes {
+		for _, p := range node.config.Inputs {
+			stats, ok := node.InputQueueStats(p.Alias)
+			if !ok {
+				continue
+			}
+
+			labels := Labels{"node": node.config.ID, "port": p.Alias}
+			m.Set(MetricQueueDepth, float64(stats.Depth), labels)
+			m.Collect(MetricQueueDropped, float64(stats.Dropped), labels)
+		}
+
+		for _, p := range node.config.Outputs {
+			stats, ok := node.OutputLimitStats(p.Alias)
+			if !ok {
+				continue
+			}
+
+			labels := Labels{"node": node.config.ID, "port": p.Alias}
+			m.Collect(MetricOutputDropped, float64(stats.Dropped), labels)
+			m.Collect(MetricOutputCoalesced, float64(stats.Coalesced), labels)
+		}
+
+		for _, loss := range node.InputLoss() {
+			m.Collect(MetricMessagesLost, float64(loss.Lost), Labels{
+				"node":        node.config.ID,
+				"port":        loss.Input,
+				"topic":       loss.Topic,
+				"source_node": loss.SourceNode,
+				"source_port": loss.Port,
+			})
+		}
+	}
+}
+
+func collectChunkMetrics(m *Metrics, chunkPub *fluxmq.ChunkingPublisher, chunkSub *fluxmq.ChunkingSubscriber) {
+	if chunkPub != nil {
+		m.Collect(MetricChunkedMessages, float64(chunkPub.Stats().Split), Labels{"direction": "out"})
+	}
+
+	if chunkSub != nil {
+		stats := chunkSub.Stats()
+		m.Collect(MetricChunkedMessages, float64(stats.Reassembled), Labels{"direction": "in"})
+		m.Collect(MetricChunksIncomplete, float64(stats.Incomplete), nil)
+		m.Collect(MetricChunksDropped, float64(stats.DroppedChunks), nil)
+	}
+}
+
+// retireNodeMetrics collects final values of previous nodes, which are being closed.
+// Counters of nodes, which are in the new config, continue from their totals,
+// series of removed nodes are deleted.
+// Previous nodes must be already removed from s.nodes, so they are not collected again.
+func (s *Service[T]) retireNodeMetrics(previous []*Node[T], config NodesConfig[T]) {
+	collectNodes(s.metrics, previous)
+
+	for _, node := range previous {
+		labels := Labels{"node": node.config.ID}
+
+		if _, err := config.GetNodeByAlias(node.config.ID); err == nil {
+			s.metrics.restartCollected(nil, labels)
+			continue
+		}
+
+		s.metrics.deleteSeries(nil, labels)
+	}
+}
+
+// publishMetrics periodically publishes metrics snapshot for the manager until ctx is done.
+func (s *Service[T]) publishMetrics(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			data, err := json.Marshal(s.metrics.Snapshot())
+			if err != nil {
+				s.logger.ErrorContext(ctx, "failed to marshal metrics", slog.String("err", err.Error()))
+				continue
+			}
+
+			err = s.Pub().Publish(s.topics.Metrics(), message.NewMessage(watermill.NewUUID(), data))
+			if err != nil {
+				s.logger.ErrorContext(ctx, "failed to publish metrics", slog.String("err", err.Error()))
+			}
+		}
+	}
+}
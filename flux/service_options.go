@@ -5,7 +5,9 @@ import (
 
 	"github.com/ThreeDotsLabs/watermill/message"
 
+	"github.com/flux-agi/flux_go/fluxbag"
 	"github.com/flux-agi/flux_go/fluxmq"
+	"github.com/flux-agi/flux_go/fluxtrace"
 )
 
 type ServiceOptions struct {
@@ -14,6 +16,17 @@ type ServiceOptions struct {
 	sub    message.Subscriber
 	call   fluxmq.Caller
 	state  *State
+	tracer *fluxtrace.Tracer
+
+	logForwarding        LogForwarderOptions
+	disableLogForwarding bool
+
+	deadLetterCapacity int
+	legacyPortTopics   bool
+	validationMode     ValidationMode
+	cipher             *PayloadCipher
+	maxDecodedSize     int
+	recording          *fluxbag.RecorderConfig
 }
 
 type ServiceOption func(*ServiceOptions)
@@ -47,3 +60,73 @@ func WithServiceState(state *State) ServiceOption {
 		o.state = state
 	}
 }
+
+// WithServiceTracer sets tracer for spans of node handlers and published messages.
+// By default, fluxtrace.Default is used.
+func WithServiceTracer(tracer *fluxtrace.Tracer) ServiceOption {
+	return func(o *ServiceOptions) {
+		o.tracer = tracer
+	}
+}
+
+// WithLogForwarding configures forwarding of service logs to the manager.
+func WithLogForwarding(options LogForwarderOptions) ServiceOption {
+	return func(o *ServiceOptions) {
+		o.logForwarding = options
+		o.disableLogForwarding = false
+	}
+}
+
+// WithoutLogForwarding disables forwarding of service logs to the manager.
+func WithoutLogForwarding() ServiceOption {
+	return func(o *ServiceOptions) {
+		o.disableLogForwarding = true
+	}
+}
+
+// WithDeadLetterQueue enables dead-letter queue: messages, which handlers failed after all retries,
+// are published into service.<id>.dlq topic, and the latest capacity of them are kept for replay.
+func WithDeadLetterQueue(capacity int) ServiceOption {
+	return func(o *ServiceOptions) {
+		o.deadLetterCapacity = capacity
+	}
+}
+
+// WithLegacyPortTopics makes Node.Push publish into node/<id>/<port> topic
+// in addition to the topics of the output port, and allows pushing into undeclared ports.
+func WithLegacyPortTopics() ServiceOption {
+	return func(o *ServiceOptions) {
+		o.legacyPortTopics = true
+	}
+}
+
+// WithPortValidation sets what service does, when declared ports of the config
+// do not match registered handlers. By default, issues are only reported.
+func WithPortValidation(mode ValidationMode) ServiceOption {
+	return func(o *ServiceOptions) {
+		o.validationMode = mode
+	}
+}
+
+// WithPayloadEncryption sets cipher of encrypted ports, see Port.Encrypt and EncryptNodePort.
+func WithPayloadEncryption(cipher *PayloadCipher) ServiceOption {
+	return func(o *ServiceOptions) {
+		o.cipher = cipher
+	}
+}
+
+// WithMaxDecodedSize sets maximal size of decompressed input payloads, DefaultMaxDecodedSize by default.
+// Larger messages are logged and dropped, so compressed payloads cannot exhaust memory of the service.
+func WithMaxDecodedSize(size int) ServiceOption {
+	return func(o *ServiceOptions) {
+		o.maxDecodedSize = size
+	}
+}
+
+// WithRecording records all messages received and published by the service into bag files from the start.
+// Without it, recording can be started with service.<id>.record topic into FLUX_BAG_DIR.
+func WithRecording(config fluxbag.RecorderConfig) ServiceOption {
+	return func(o *ServiceOptions) {
+		o.recording = &config
+	}
+}
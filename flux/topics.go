@@ -2,6 +2,14 @@ package flux
 
 import "fmt"
 
+// TopicGlobalTick is a topic, where the manager broadcasts ticks of nodes with global timer.
+const TopicGlobalTick = "service.tick"
+
+// TopicLegacyGlobalTick is a global tick topic of managers released before TopicGlobalTick.
+//
+// Deprecated: nodes subscribe to it during the transition period only, managers should publish into TopicGlobalTick.
+const TopicLegacyGlobalTick = "service/tick"
+
 type ServiceTopics struct {
 	service string
 }
@@ -26,8 +34,8 @@ func (t *ServiceTopics) Restart() string { return fmt.Sprintf("service.%s.restar
 func (t *ServiceTopics) PushDevelopmentMode(guid string) string {
 	return "service.development_mode." + guid
 }
-func (t *ServiceTopics) Errors() string     { return "service." + ".error" }
-func (t *ServiceTopics) GlobalTick() string { return "service.tick" }
+func (t *ServiceTopics) Errors() string     { return fmt.Sprintf("service.%s.error", t.service) }
+func (t *ServiceTopics) GlobalTick() string { return TopicGlobalTick }
 func (t *ServiceTopics) GetCommonState() string {
 	return fmt.Sprintf("service.%s.get_common_state", t.service)
 }
@@ -42,6 +50,51 @@ func (t *ServiceTopics) SetCommonData() string {
 	return fmt.Sprintf("service.%s.set_common_data", t.service)
 }
 
+// Metrics returns topic, where service periodically publishes snapshot of its metrics.
+func (t *ServiceTopics) Metrics() string {
+	return fmt.Sprintf("service.%s.metrics", t.service)
+}
+
+// Logs returns topic, where service forwards its log records.
+func (t *ServiceTopics) Logs() string {
+	return fmt.Sprintf("service.%s.logs", t.service)
+}
+
+// LogLevel returns topic, which changes level of the forwarded logs.
+func (t *ServiceTopics) LogLevel() string {
+	return fmt.Sprintf("service.%s.log_level", t.service)
+}
+
+// Quarantine returns topic, where service notifies about quarantined and resumed nodes.
+func (t *ServiceTopics) Quarantine() string {
+	return fmt.Sprintf("service.%s.quarantine", t.service)
+}
+
+// DeadLetters returns topic, where service publishes messages, which handlers failed after all retries.
+func (t *ServiceTopics) DeadLetters() string {
+	return fmt.Sprintf("service.%s.dlq", t.service)
+}
+
+// Validation returns topic, where service publishes issues of the received config.
+func (t *ServiceTopics) Validation() string {
+	return fmt.Sprintf("service.%s.validation", t.service)
+}
+
+// Record returns topic, which starts and stops recording of the service traffic.
+func (t *ServiceTopics) Record() string {
+	return fmt.Sprintf("service.%s.record", t.service)
+}
+
+// Registration returns topic, where service announces its node types and their ports.
+func (t *ServiceTopics) Registration() string {
+	return fmt.Sprintf("service.%s.registration", t.service)
+}
+
+// RequestRegistration returns topic, where the manager asks service to announce itself again.
+func (t *ServiceTopics) RequestRegistration() string {
+	return fmt.Sprintf("service.%s.request_registration", t.service)
+}
+
 // IDEStatus returns topic for subscribing on IDE statuses.
 //
 // When client connects to the manager, manager sends "status": "CONNECTED" into this topic.
@@ -49,3 +102,27 @@ func (t *ServiceTopics) SetCommonData() string {
 func (t *ServiceTopics) IDEStatus() string {
 	return "ide.status"
 }
+
+// NodeTopics are topics of the node, which are used by the manager and tools.
+type NodeTopics struct {
+	node string
+}
+
+func NewNodeTopics(node string) *NodeTopics {
+	return &NodeTopics{node: node}
+}
+
+// Port returns legacy topic of the node port, see WithLegacyPortTopics.
+func (t *NodeTopics) Port(port string) string {
+	return fmt.Sprintf("node/%s/%s", t.node, port)
+}
+
+// Event returns topic of the node event, e.g. start, stop or reset.
+func (t *NodeTopics) Event(event string) string {
+	return fmt.Sprintf("node/%s/event/%s", t.node, event)
+}
+
+// Settings returns topic, which replaces settings of the node.
+func (t *NodeTopics) Settings() string {
+	return fmt.Sprintf("node.%s.set_settings", t.node)
+}
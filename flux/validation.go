@@ -0,0 +1,210 @@
+package flux
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ValidationMode defines what service does, when node config does not match registered handlers.
+type ValidationMode string
+
+const (
+	// ValidationModeWarn logs and reports the issues, all nodes are started.
+	ValidationModeWarn ValidationMode = "WARN"
+	// ValidationModeFailNode keeps nodes with issues in ERROR status without handlers.
+	ValidationModeFailNode ValidationMode = "FAIL_NODE"
+	// ValidationModeFailConfig rejects the whole config, running nodes of the previous config are kept.
+	ValidationModeFailConfig ValidationMode = "FAIL_CONFIG"
+)
+
+var ErrInvalidConfig = errors.New("flux: invalid config")
+
+type ValidationIssueKind string
+
+const (
+	// ValidationIssueUnhandledInput is an input port declared in node config without handler.
+	ValidationIssueUnhandledInput ValidationIssueKind = "UNHANDLED_INPUT"
+	// ValidationIssueUnknownHandlerPort is a handler of port, which is not declared by any node.
+	// It is a warning only: service may run node types, which are not deployed by the config.
+	ValidationIssueUnknownHandlerPort ValidationIssueKind = "UNKNOWN_HANDLER_PORT"
+	// ValidationIssueDuplicateTopic is a topic subscribed by several inputs of one node,
+	// it is reported for every input after the first one. Different nodes may consume the same topic.
+	ValidationIssueDuplicateTopic ValidationIssueKind = "DUPLICATE_TOPIC"
+)
+
+// warning reports whether issues of the kind are only reported, they never fail nodes or config.
+func (k ValidationIssueKind) warning() bool {
+	return k == ValidationIssueUnknownHandlerPort
+}
+
+type ValidationIssue struct {
+	Kind    ValidationIssueKind `json:"kind"`
+	NodeID  string              `json:"node_id,omitempty"`
+	Port    string              `json:"port"`
+	Topic   string              `json:"topic,omitempty"`
+	Message string              `json:"message"`
+}
+
+// ValidationReport is published into service.<id>.validation topic after every config is received.
+type ValidationReport struct {
+	Service     string            `json:"service"`
+	Mode        ValidationMode    `json:"mode"`
+	Issues      []ValidationIssue `json:"issues"`
+	FailedNodes []string          `json:"failed_nodes,omitempty"`
+	Rejected    bool              `json:"rejected"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// nodeIssues returns issues of the node with given id.
+func (r ValidationReport) nodeIssues(nodeID string) []ValidationIssue {
+	var issues []ValidationIssue
+
+	for _, issue := range r.Issues {
+		if issue.NodeID == nodeID {
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues
+}
+
+// handledPorts returns ports with subscribe handlers or latches.
+func (n *NodeHandlers[T]) handledPorts() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ports := make([]string, 0, len(n.onSubscribe)+len(n.latches))
+	for port := range n.onSubscribe {
+		ports = append(ports, port)
+	}
+
+	for port := range n.latches {
+		if !slices.Contains(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+
+	slices.Sort(ports)
+
+	return ports
+}
+
+// validateNodes checks declared input ports of the config against registered handlers.
+func validateNodes[T any](nodes NodesConfig[T], handled []string) []ValidationIssue {
+	var issues []ValidationIssue
+
+	declared := make(map[string]bool)
+
+	for _, node := range nodes {
+		// subscribers are the first inputs of the node, which subscribed topics
+		subscribers := make(map[string]string)
+
+		for _, p := range node.Inputs {
+			if p == nil {
+				continue
+			}
+
+			declared[p.Alias] = true
+
+			if !slices.Contains(handled, p.Alias) {
+				issues = append(issues, ValidationIssue{
+					Kind:    ValidationIssueUnhandledInput,
+					NodeID:  node.ID,
+					Port:    p.Alias,
+					Topic:   "",
+					Message: fmt.Sprintf("input %q of node %s has no handler", p.Alias, node.ID),
+				})
+			}
+
+			for _, topic := range uniqueTopics(p.Topics) {
+				if other, ok := subscribers[topic]; ok {
+					issues = append(issues, ValidationIssue{
+						Kind:   ValidationIssueDuplicateTopic,
+						NodeID: node.ID,
+						Port:   p.Alias,
+						Topic:  topic,
+						Message: fmt.Sprintf(
+							"topic %s is subscribed by inputs %q and %q of node %s",
+							topic, other, p.Alias, node.ID,
+						),
+					})
+					continue
+				}
+
+				subscribers[topic] = p.Alias
+			}
+		}
+	}
+
+	for _, port := range handled {
+		if !declared[port] {
+			issues = append(issues, ValidationIssue{
+				Kind:    ValidationIssueUnknownHandlerPort,
+				NodeID:  "",
+				Port:    port,
+				Topic:   "",
+				Message: fmt.Sprintf("handler of port %q is registered, but no node declares it", port),
+			})
+		}
+	}
+
+	return issues
+}
+
+// validateConfig validates config, logs and publishes the report.
+// It returns ErrInvalidConfig, when the config is rejected.
+func (s *Service[T]) validateConfig(nodes NodesConfig[T]) (ValidationReport, error) {
+	report := ValidationReport{
+		Service:     s.serviceID,
+		Mode:        s.validationMode,
+		Issues:      validateNodes(nodes, s.nodeHandlers.handledPorts()),
+		FailedNodes: nil,
+		Rejected:    false,
+		Timestamp:   time.Now().UTC(),
+	}
+
+	if len(report.Issues) == 0 {
+		return report, nil
+	}
+
+	messages := make([]string, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		if !issue.Kind.warning() {
+			messages = append(messages, issue.Message)
+		}
+
+		s.logger.Warn(
+			"config validation issue",
+			slog.String("kind", string(issue.Kind)),
+			slog.String("node_id", issue.NodeID),
+			slog.String("port", issue.Port),
+			slog.String("message", issue.Message),
+		)
+	}
+
+	switch s.validationMode {
+	case ValidationModeFailConfig:
+		report.Rejected = slices.ContainsFunc(report.Issues, func(issue ValidationIssue) bool {
+			return !issue.Kind.warning()
+		})
+	case ValidationModeFailNode:
+		for _, issue := range report.Issues {
+			if !issue.Kind.warning() && issue.NodeID != "" && !slices.Contains(report.FailedNodes, issue.NodeID) {
+				report.FailedNodes = append(report.FailedNodes, issue.NodeID)
+			}
+		}
+	case ValidationModeWarn:
+	}
+
+	s.publishRecord(s.topics.Validation(), report)
+
+	if report.Rejected {
+		return report, fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(messages, "; "))
+	}
+
+	return report, nil
+}
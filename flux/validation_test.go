@@ -0,0 +1,172 @@
+package flux
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestValidateNodes(t *testing.T) {
+	t.Parallel()
+
+	input := func(alias string, topics ...string) *Port {
+		return &Port{Alias: alias, Topics: topics}
+	}
+
+	tests := []struct {
+		name    string
+		nodes   NodesConfig[any]
+		handled []string
+		want    []ValidationIssue
+	}{
+		{
+			name: "valid",
+			nodes: NodesConfig[any]{
+				{ID: "a", Inputs: []*Port{input("in", "t1")}},
+				{ID: "b", Inputs: []*Port{input("in", "t2"), nil}},
+			},
+			handled: []string{"in"},
+			want:    nil,
+		},
+		{
+			name: "unhandled input",
+			nodes: NodesConfig[any]{
+				{ID: "a", Inputs: []*Port{input("in", "t1"), input("extra", "t2")}},
+			},
+			handled: []string{"in"},
+			want: []ValidationIssue{
+				{Kind: ValidationIssueUnhandledInput, NodeID: "a", Port: "extra"},
+			},
+		},
+		{
+			name: "unknown handler port",
+			nodes: NodesConfig[any]{
+				{ID: "a", Inputs: []*Port{input("in", "t1")}},
+			},
+			handled: []string{"in", "other"},
+			want: []ValidationIssue{
+				{Kind: ValidationIssueUnknownHandlerPort, Port: "other"},
+			},
+		},
+		{
+			name: "duplicate topic within node",
+			nodes: NodesConfig[any]{
+				{ID: "a", Inputs: []*Port{input("in", "t1"), input("other", "t1")}},
+			},
+			handled: []string{"in", "other"},
+			want: []ValidationIssue{
+				{Kind: ValidationIssueDuplicateTopic, NodeID: "a", Port: "other", Topic: "t1"},
+			},
+		},
+		{
+			name: "nodes consume the same topic",
+			nodes: NodesConfig[any]{
+				{ID: "a", Inputs: []*Port{input("in", "t1")}},
+				{ID: "b", Inputs: []*Port{input("in", "t2", "t1")}},
+				{ID: "c", Inputs: []*Port{input("in", "t1")}},
+			},
+			handled: []string{"in"},
+			want:    nil,
+		},
+		{
+			name: "repeated topic of the same port",
+			nodes: NodesConfig[any]{
+				{ID: "a", Inputs: []*Port{input("in", "t1", "t1")}},
+			},
+			handled: []string{"in"},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := validateNodes(tt.nodes, tt.handled)
+
+			// messages are for humans, only check other fields
+			for i := range got {
+				if got[i].Message == "" {
+					t.Errorf("issue %d has no message", i)
+				}
+				got[i].Message = ""
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("issues %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+
+	input := func(alias string, topics ...string) *Port {
+		return &Port{Alias: alias, Topics: topics}
+	}
+
+	handler := func(_ context.Context, _ NodeConfig[any], _ []byte) error { return nil }
+
+	tests := []struct {
+		name         string
+		mode         ValidationMode
+		nodes        NodesConfig[any]
+		wantRejected bool
+		wantFailed   []string
+	}{
+		{
+			name: "unknown handler port does not reject config",
+			mode: ValidationModeFailConfig,
+			// handler of "depth" belongs to a node type, which the config does not deploy
+			nodes:        NodesConfig[any]{{ID: "a", Inputs: []*Port{input("frame", "t1")}}},
+			wantRejected: false,
+			wantFailed:   nil,
+		},
+		{
+			name:         "unhandled input rejects config",
+			mode:         ValidationModeFailConfig,
+			nodes:        NodesConfig[any]{{ID: "a", Inputs: []*Port{input("frame", "t1"), input("extra", "t2")}}},
+			wantRejected: true,
+			wantFailed:   nil,
+		},
+		{
+			name: "fan-out does not fail nodes",
+			mode: ValidationModeFailNode,
+			nodes: NodesConfig[any]{
+				{ID: "a", Inputs: []*Port{input("frame", "t1")}},
+				{ID: "b", Inputs: []*Port{input("frame", "t1"), input("depth", "t1")}},
+			},
+			wantRejected: false,
+			wantFailed:   []string{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Service[any]{
+				serviceID:       "svc",
+				logger:          slog.Default(),
+				topics:          NewTopics("svc"),
+				validationMode:  tt.mode,
+				connectionMutex: new(sync.RWMutex),
+			}
+			s.nodeHandlers.OnSubscribeContext("frame", handler)
+			s.nodeHandlers.OnSubscribeContext("depth", handler)
+
+			report, err := s.validateConfig(tt.nodes)
+			if report.Rejected != tt.wantRejected || errors.Is(err, ErrInvalidConfig) != tt.wantRejected {
+				t.Fatalf("rejected %v with error %v, want rejected %v", report.Rejected, err, tt.wantRejected)
+			}
+
+			if !slices.Equal(report.FailedNodes, tt.wantFailed) {
+				t.Fatalf("failed nodes %v, want %v", report.FailedNodes, tt.wantFailed)
+			}
+		})
+	}
+}
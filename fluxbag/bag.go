@@ -0,0 +1,298 @@
+// Package fluxbag records messages into compact append-only bag files and reads them back.
+//
+// A bag file starts with a magic header, followed by records. Every record is
+// a uvarint length, a body and its CRC-32 checksum. The body holds direction, timestamp,
+// topic, message uuid, metadata and payload. A record truncated by a crash ends the bag.
+package fluxbag
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Extension is an extension of bag files.
+const Extension = ".fluxbag"
+
+const magic = "FLUXBAG1"
+
+// maxRecordSize protects reader from allocating memory for a corrupted length.
+const maxRecordSize = 1 << 30
+
+var (
+	ErrInvalidBag      = errors.New("fluxbag: invalid bag file")
+	ErrCorruptedRecord = errors.New("fluxbag: corrupted record")
+)
+
+// Direction tells whether the message was received or published by the service.
+type Direction byte
+
+const (
+	DirectionIn  Direction = 'i'
+	DirectionOut Direction = 'o'
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionIn:
+		return "in"
+	case DirectionOut:
+		return "out"
+	default:
+		return "unknown"
+	}
+}
+
+// Record is a recorded message.
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Topic     string
+	UUID      string
+	Metadata  message.Metadata
+	Payload   []byte
+}
+
+// NewRecord creates record of the message.
+func NewRecord(direction Direction, topic string, msg *message.Message) Record {
+	return Record{
+		Time:      time.Now(),
+		Direction: direction,
+		Topic:     topic,
+		UUID:      msg.UUID,
+		Metadata:  maps.Clone(msg.Metadata),
+		Payload:   msg.Payload,
+	}
+}
+
+// Message returns a new message with uuid, metadata and payload of the record.
+func (r Record) Message() *message.Message {
+	msg := message.NewMessage(r.UUID, slices.Clone(r.Payload))
+	if r.Metadata != nil {
+		msg.Metadata = maps.Clone(r.Metadata)
+	}
+
+	return msg
+}
+
+// Writer writes records into bag.
+type Writer struct {
+	w       *bufio.Writer
+	written int64
+	buf     []byte
+}
+
+// NewWriter writes bag header into w and returns writer of records.
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := &Writer{w: bufio.NewWriter(w), written: 0, buf: nil}
+
+	n, err := writer.w.WriteString(magic)
+	if err != nil {
+		return nil, fmt.Errorf("fluxbag: failed to write header: %w", err)
+	}
+
+	writer.written = int64(n)
+
+	return writer, nil
+}
+
+// Write appends record to the bag. Records are buffered until Flush.
+func (w *Writer) Write(record Record) error {
+	body := w.buf[:0]
+	body = append(body, byte(record.Direction))
+	body = binary.AppendVarint(body, record.Time.UnixNano())
+	body = appendString(body, record.Topic)
+	body = appendString(body, record.UUID)
+
+	body = binary.AppendUvarint(body, uint64(len(record.Metadata)))
+	for _, key := range slices.Sorted(maps.Keys(record.Metadata)) {
+		body = appendString(body, key)
+		body = appendString(body, record.Metadata[key])
+	}
+
+	body = appendBytes(body, record.Payload)
+	w.buf = body
+
+	head := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64), uint64(len(body)))
+	sum := binary.LittleEndian.AppendUint32(make([]byte, 0, 4), crc32.ChecksumIEEE(body))
+
+	for _, part := range [][]byte{head, body, sum} {
+		n, err := w.w.Write(part)
+		w.written += int64(n)
+		if err != nil {
+			return fmt.Errorf("fluxbag: failed to write record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Written returns count of bytes written into the bag, including buffered ones.
+func (w *Writer) Written() int64 {
+	return w.written
+}
+
+// Flush writes buffered records into the underlying writer.
+func (w *Writer) Flush() error {
+	if err := w.w.Flush(); err != nil {
+		return fmt.Errorf("fluxbag: failed to flush: %w", err)
+	}
+
+	return nil
+}
+
+func appendString(buf []byte, value string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendBytes(buf []byte, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// Reader reads records from bag.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader checks bag header and returns reader of records.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(reader.r, header); err != nil || string(header) != magic {
+		return nil, ErrInvalidBag
+	}
+
+	return reader, nil
+}
+
+// Next returns the next record. It returns io.EOF at the end of bag,
+// including a record truncated by a crash.
+func (r *Reader) Next() (Record, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Record{}, io.EOF
+	}
+
+	if size > maxRecordSize {
+		return Record{}, ErrCorruptedRecord
+	}
+
+	body := make([]byte, size+4)
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		return Record{}, io.EOF
+	}
+
+	body, sum := body[:size], body[size:]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(sum) {
+		return Record{}, ErrCorruptedRecord
+	}
+
+	return decodeRecord(body)
+}
+
+func decodeRecord(body []byte) (Record, error) {
+	var record Record
+
+	d := decoder{buf: body, err: nil}
+
+	record.Direction = Direction(d.byte())
+	record.Time = time.Unix(0, d.varint())
+	record.Topic = d.string()
+	record.UUID = d.string()
+
+	count := d.uvarint()
+	if count > uint64(len(body)) {
+		return Record{}, ErrCorruptedRecord
+	}
+
+	record.Metadata = make(message.Metadata, count)
+	for range count {
+		key := d.string()
+		record.Metadata[key] = d.string()
+	}
+
+	record.Payload = d.bytes()
+
+	if d.err != nil {
+		return Record{}, d.err
+	}
+
+	return record, nil
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.buf) < 1 {
+		d.err = ErrCorruptedRecord
+		return 0
+	}
+
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+
+	return b
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	value, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = ErrCorruptedRecord
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+
+	return value
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	value, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = ErrCorruptedRecord
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+
+	return value
+}
+
+func (d *decoder) bytes() []byte {
+	size := d.uvarint()
+	if d.err != nil || size > uint64(len(d.buf)) {
+		d.err = ErrCorruptedRecord
+		return nil
+	}
+
+	value := d.buf[:size]
+	d.buf = d.buf[size:]
+
+	return value
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
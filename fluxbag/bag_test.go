@@ -0,0 +1,182 @@
+package fluxbag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func testRecords() []Record {
+	return []Record{
+		{
+			Time:      time.Unix(0, 1700000000000000001),
+			Direction: DirectionIn,
+			Topic:     "node.camera.image",
+			UUID:      "uuid-1",
+			Metadata:  message.Metadata{"flux_port": "image", "flux_sequence": "1"},
+			Payload:   []byte(`{"frame":1}`),
+		},
+		{
+			Time:      time.Unix(0, 1700000000000000002),
+			Direction: DirectionOut,
+			Topic:     "service.a.status",
+			UUID:      "uuid-2",
+			Metadata:  message.Metadata{},
+			Payload:   []byte{},
+		},
+	}
+}
+
+func writeBag(t *testing.T, records []Record) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if writer.Written() != int64(buf.Len()) {
+		t.Fatalf("written %d, bag has %d bytes", writer.Written(), buf.Len())
+	}
+
+	return buf.Bytes()
+}
+
+func readBag(data []byte) ([]Record, error) {
+	reader, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+
+		records = append(records, record)
+	}
+}
+
+func TestBagReader(t *testing.T) {
+	t.Parallel()
+
+	records := testRecords()
+	bag := writeBag(t, records)
+	first := len(writeBag(t, records[:1]))
+
+	corrupted := bytes.Clone(bag)
+	corrupted[first-5] ^= 0xff // last byte of the first record body, before its checksum
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []Record
+		wantErr error
+	}{
+		{name: "round trip", data: bag, want: records, wantErr: nil},
+		{name: "empty bag", data: []byte(magic), want: nil, wantErr: nil},
+		{name: "truncated record", data: bag[:len(bag)-3], want: records[:1], wantErr: nil},
+		{name: "truncated length", data: append(bytes.Clone(bag[:first]), 0x80), want: records[:1], wantErr: nil},
+		{name: "crc mismatch", data: corrupted, want: nil, wantErr: ErrCorruptedRecord},
+		{name: "invalid header", data: []byte("NOTABAG1"), want: nil, wantErr: ErrInvalidBag},
+		{name: "short header", data: []byte("FLUX"), want: nil, wantErr: ErrInvalidBag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := readBag(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d records, want %d", len(got), len(tt.want))
+			}
+
+			for i := range got {
+				if !equalRecords(got[i], tt.want[i]) {
+					t.Fatalf("record %d is %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func equalRecords(a, b Record) bool {
+	return a.Time.Equal(b.Time) &&
+		a.Direction == b.Direction &&
+		a.Topic == b.Topic &&
+		a.UUID == b.UUID &&
+		maps.Equal(a.Metadata, b.Metadata) &&
+		bytes.Equal(a.Payload, b.Payload)
+}
+
+func TestFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	names := []string{
+		"cam-20240102T030405.000000002.fluxbag",
+		"cam-20240102T030405.000000001.fluxbag",
+		"cam-front-20240102T030405.000000001.fluxbag",
+		"cam-20240102T030405.000000003.tmp",
+		"camera-20240102T030405.000000001.fluxbag",
+		"cam-latest.fluxbag",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Files(dir, "cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "cam-20240102T030405.000000001.fluxbag"),
+		filepath.Join(dir, "cam-20240102T030405.000000002.fluxbag"),
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("files %v, want %v", got, want)
+	}
+
+	got, err = Files(dir, "cam-front")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("files of cam-front %v, want one file", got)
+	}
+}
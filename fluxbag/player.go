@@ -0,0 +1,223 @@
+package fluxbag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// PlayerConfig configures replaying of recorded messages.
+type PlayerConfig struct {
+	// Speed scales original timing, e.g. 1 is real time and 2 is twice faster.
+	// Zero plays as fast as possible.
+	Speed float64
+	// Topics are patterns of played topics, "*" matches any characters. Empty plays all topics.
+	Topics []string
+	// Directions are played directions, e.g. DirectionIn to feed a service with what it received.
+	// Empty plays all records.
+	Directions []Direction
+	// Start and End are offsets from the first record, zero End plays until the end.
+	Start time.Duration
+	End   time.Duration
+	// Remap returns topic, where the record is published. Nil keeps the original topic.
+	Remap func(topic string) string
+}
+
+// PlayStats are counters of played records.
+type PlayStats struct {
+	Played  int `json:"played"`
+	Skipped int `json:"skipped"`
+}
+
+// Player republishes recorded messages through the publisher.
+type Player struct {
+	pub    message.Publisher
+	config PlayerConfig
+}
+
+func NewPlayer(pub message.Publisher, config PlayerConfig) *Player {
+	return &Player{pub: pub, config: config}
+}
+
+// PlayFiles plays bag files one after another, offsets and timing are shared between files.
+func (p *Player) PlayFiles(ctx context.Context, paths ...string) (PlayStats, error) {
+	var readers []*Reader
+
+	for _, path := range paths {
+		file, err := os.Open(path) //nolint:gosec
+		if err != nil {
+			return PlayStats{}, fmt.Errorf("fluxbag: failed to open bag file: %w", err)
+		}
+		defer file.Close()
+
+		reader, err := NewReader(file)
+		if err != nil {
+			return PlayStats{}, fmt.Errorf("%s: %w", path, err)
+		}
+
+		readers = append(readers, reader)
+	}
+
+	return p.Play(ctx, readers...)
+}
+
+// Play plays records of the readers one after another until the end or ctx is done.
+func (p *Player) Play(ctx context.Context, readers ...*Reader) (PlayStats, error) {
+	var (
+		stats     PlayStats
+		first     time.Time
+		startedAt time.Time
+	)
+
+	for _, reader := range readers {
+		for {
+			record, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				return stats, err
+			}
+
+			if first.IsZero() {
+				first = record.Time
+			}
+
+			offset := record.Time.Sub(first)
+			if p.config.End > 0 && offset > p.config.End {
+				return stats, nil
+			}
+
+			if offset < p.config.Start || !p.matches(record) {
+				stats.Skipped++
+				continue
+			}
+
+			if startedAt.IsZero() {
+				startedAt = time.Now()
+			}
+
+			if err := p.wait(ctx, startedAt, offset-p.config.Start); err != nil {
+				return stats, err
+			}
+
+			topic := record.Topic
+			if p.config.Remap != nil {
+				topic = p.config.Remap(topic)
+			}
+
+			if err := p.pub.Publish(topic, record.Message()); err != nil {
+				return stats, fmt.Errorf("fluxbag: failed to publish record: %w", err)
+			}
+
+			stats.Played++
+		}
+	}
+
+	return stats, nil
+}
+
+func (p *Player) matches(record Record) bool {
+	if len(p.config.Directions) > 0 && !slices.Contains(p.config.Directions, record.Direction) {
+		return false
+	}
+
+	if len(p.config.Topics) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(p.config.Topics, func(pattern string) bool {
+		return MatchTopic(pattern, record.Topic)
+	})
+}
+
+// wait sleeps until the record offset scaled by speed, measured from startedAt.
+func (p *Player) wait(ctx context.Context, startedAt time.Time, offset time.Duration) error {
+	if p.config.Speed <= 0 {
+		return ctx.Err() //nolint:wrapcheck
+	}
+
+	delay := time.Until(startedAt.Add(time.Duration(float64(offset) / p.config.Speed)))
+	if delay <= 0 {
+		return ctx.Err() //nolint:wrapcheck
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck
+	case <-timer.C:
+		return nil
+	}
+}
+
+// MatchTopic reports whether topic matches the pattern, where "*" matches any characters.
+func MatchTopic(pattern, topic string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == topic
+	}
+
+	if !strings.HasPrefix(topic, parts[0]) {
+		return false
+	}
+
+	topic = topic[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(topic, part)
+		if i < 0 {
+			return false
+		}
+
+		topic = topic[i+len(part):]
+	}
+
+	return strings.HasSuffix(topic, parts[len(parts)-1])
+}
+
+// ReadFiles returns all records of bag files.
+func ReadFiles(paths ...string) ([]Record, error) {
+	var records []Record
+
+	for _, path := range paths {
+		file, err := os.Open(path) //nolint:gosec
+		if err != nil {
+			return nil, fmt.Errorf("fluxbag: failed to open bag file: %w", err)
+		}
+
+		reader, err := NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		for {
+			record, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				_ = file.Close()
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+
+			records = append(records, record)
+		}
+
+		_ = file.Close()
+	}
+
+	return records, nil
+}
@@ -0,0 +1,260 @@
+package fluxbag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// played collects topics and uuids of published messages.
+type played struct {
+	mu     sync.Mutex
+	topics []string
+	uuids  []string
+}
+
+func (p *played) Publish(topic string, messages ...*message.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, msg := range messages {
+		p.topics = append(p.topics, topic)
+		p.uuids = append(p.uuids, msg.UUID)
+	}
+
+	return nil
+}
+
+func (p *played) Close() error {
+	return nil
+}
+
+// playRecords returns records of topics a, b, a, b in and out, 10ms apart.
+func playRecords() []Record {
+	start := time.Unix(1700000000, 0)
+
+	record := func(i int, direction Direction, topic string) Record {
+		return Record{
+			Time:      start.Add(time.Duration(i) * 10 * time.Millisecond),
+			Direction: direction,
+			Topic:     topic,
+			UUID:      string(rune('0' + i)),
+			Metadata:  message.Metadata{},
+			Payload:   nil,
+		}
+	}
+
+	return []Record{
+		record(0, DirectionIn, "node.a.in"),
+		record(1, DirectionOut, "node.b.out"),
+		record(2, DirectionIn, "node.a.in"),
+		record(3, DirectionOut, "node.b.out"),
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{pattern: "node.a.in", topic: "node.a.in", want: true},
+		{pattern: "node.a.in", topic: "node.a.inx", want: false},
+		{pattern: "*", topic: "anything", want: true},
+		{pattern: "node.*", topic: "node.a.in", want: true},
+		{pattern: "node.*", topic: "service.a", want: false},
+		{pattern: "*.in", topic: "node.a.in", want: true},
+		{pattern: "node.*.in", topic: "node.a.in", want: true},
+		{pattern: "node.*.in", topic: "node.a.out", want: false},
+		{pattern: "node.*.*.x", topic: "node.a.b.x", want: true},
+		{pattern: "a*a", topic: "a", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.topic, func(t *testing.T) {
+			t.Parallel()
+
+			if got := MatchTopic(tt.pattern, tt.topic); got != tt.want {
+				t.Fatalf("MatchTopic(%q, %q) = %v, want %v", tt.pattern, tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayerPlay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		config     PlayerConfig
+		wantTopics []string
+		wantUUIDs  []string
+		wantStats  PlayStats
+	}{
+		{
+			name:       "all records in order",
+			config:     PlayerConfig{},
+			wantTopics: []string{"node.a.in", "node.b.out", "node.a.in", "node.b.out"},
+			wantUUIDs:  []string{"0", "1", "2", "3"},
+			wantStats:  PlayStats{Played: 4, Skipped: 0},
+		},
+		{
+			name:       "topic pattern",
+			config:     PlayerConfig{Topics: []string{"*.in"}},
+			wantTopics: []string{"node.a.in", "node.a.in"},
+			wantUUIDs:  []string{"0", "2"},
+			wantStats:  PlayStats{Played: 2, Skipped: 2},
+		},
+		{
+			name:       "direction",
+			config:     PlayerConfig{Directions: []Direction{DirectionOut}},
+			wantTopics: []string{"node.b.out", "node.b.out"},
+			wantUUIDs:  []string{"1", "3"},
+			wantStats:  PlayStats{Played: 2, Skipped: 2},
+		},
+		{
+			name:       "start and end offsets",
+			config:     PlayerConfig{Start: 10 * time.Millisecond, End: 20 * time.Millisecond},
+			wantTopics: []string{"node.b.out", "node.a.in"},
+			wantUUIDs:  []string{"1", "2"},
+			wantStats:  PlayStats{Played: 2, Skipped: 1},
+		},
+		{
+			name:       "remap",
+			config:     PlayerConfig{Topics: []string{"node.b.*"}, Remap: func(topic string) string { return "test." + topic }},
+			wantTopics: []string{"test.node.b.out", "test.node.b.out"},
+			wantUUIDs:  []string{"1", "3"},
+			wantStats:  PlayStats{Played: 2, Skipped: 2},
+		},
+	}
+
+	bag := writeBag(t, playRecords())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader, err := NewReader(bytes.NewReader(bag))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pub := &played{mu: sync.Mutex{}, topics: nil, uuids: nil}
+
+			stats, err := NewPlayer(pub, tt.config).Play(context.Background(), reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(pub.topics, tt.wantTopics) || !slices.Equal(pub.uuids, tt.wantUUIDs) {
+				t.Fatalf("played %v %v, want %v %v", pub.topics, pub.uuids, tt.wantTopics, tt.wantUUIDs)
+			}
+
+			if stats != tt.wantStats {
+				t.Fatalf("stats %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestPlayerPlaySharesOffsetsBetweenReaders(t *testing.T) {
+	t.Parallel()
+
+	records := playRecords()
+	first := writeBag(t, records[:2])
+	second := writeBag(t, records[2:])
+
+	readers := make([]*Reader, 0, 2)
+	for _, bag := range [][]byte{first, second} {
+		reader, err := NewReader(bytes.NewReader(bag))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		readers = append(readers, reader)
+	}
+
+	pub := &played{mu: sync.Mutex{}, topics: nil, uuids: nil}
+
+	// offset of the second file is measured from the first record of the first one
+	_, err := NewPlayer(pub, PlayerConfig{Start: 15 * time.Millisecond}).Play(context.Background(), readers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"2", "3"}; !slices.Equal(pub.uuids, want) {
+		t.Fatalf("played %v, want %v", pub.uuids, want)
+	}
+}
+
+func TestPlayerSpeed(t *testing.T) {
+	t.Parallel()
+
+	// records are 30ms apart from the first one
+	bag := writeBag(t, playRecords())
+
+	tests := []struct {
+		name    string
+		speed   float64
+		atLeast time.Duration
+		atMost  time.Duration
+	}{
+		{name: "real time", speed: 1, atLeast: 30 * time.Millisecond, atMost: time.Second},
+		{name: "three times faster", speed: 3, atLeast: 10 * time.Millisecond, atMost: 28 * time.Millisecond},
+		{name: "as fast as possible", speed: 0, atLeast: 0, atMost: 15 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader, err := NewReader(bytes.NewReader(bag))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pub := &played{mu: sync.Mutex{}, topics: nil, uuids: nil}
+
+			startedAt := time.Now()
+			if _, err := NewPlayer(pub, PlayerConfig{Speed: tt.speed}).Play(context.Background(), reader); err != nil {
+				t.Fatal(err)
+			}
+
+			elapsed := time.Since(startedAt)
+			if elapsed < tt.atLeast || elapsed > tt.atMost {
+				t.Fatalf("played in %s, want between %s and %s", elapsed, tt.atLeast, tt.atMost)
+			}
+		})
+	}
+}
+
+func TestPlayerCancel(t *testing.T) {
+	t.Parallel()
+
+	reader, err := NewReader(bytes.NewReader(writeBag(t, playRecords())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	pub := &played{mu: sync.Mutex{}, topics: nil, uuids: nil}
+
+	stats, err := NewPlayer(pub, PlayerConfig{Speed: 0.1}).Play(ctx, reader)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if stats.Played != 1 {
+		t.Fatalf("played %d records before cancel, want 1", stats.Played)
+	}
+}
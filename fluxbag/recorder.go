@@ -0,0 +1,301 @@
+package fluxbag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const (
+	DefaultMaxFileSize   = 64 * 1024 * 1024
+	DefaultFlushInterval = time.Second
+)
+
+// RecorderConfig configures recording into rotated bag files.
+type RecorderConfig struct {
+	// Dir is a directory of bag files, it is created when missing.
+	Dir string
+	// Prefix is a prefix of bag file names, e.g. service id.
+	Prefix string
+	// MaxFileSize is a size of bag file, after which a new file is started.
+	MaxFileSize int64
+	// MaxFiles is a count of kept bag files, the oldest ones are removed. Zero keeps all files.
+	MaxFiles int
+	// FlushInterval is an interval of flushing buffered records into the file.
+	FlushInterval time.Duration
+}
+
+// Recorder writes messages into rotated bag files. Messages are recorded only after Start.
+type Recorder struct {
+	config RecorderConfig
+	active atomic.Bool
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *Writer
+	timer  *time.Timer
+	err    error
+}
+
+func NewRecorder(config RecorderConfig) *Recorder {
+	if config.Dir == "" {
+		config.Dir = "."
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = "flux"
+	}
+
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = DefaultMaxFileSize
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultFlushInterval
+	}
+
+	return &Recorder{
+		config: config,
+		active: atomic.Bool{},
+		mu:     sync.Mutex{},
+		file:   nil,
+		writer: nil,
+		timer:  nil,
+		err:    nil,
+	}
+}
+
+// Start starts recording into a new bag file, it does nothing when recording is active.
+func (r *Recorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.active.Load() {
+		return nil
+	}
+
+	if err := os.MkdirAll(r.config.Dir, 0o755); err != nil {
+		return fmt.Errorf("fluxbag: failed to create bag dir: %w", err)
+	}
+
+	if err := r.rotate(); err != nil {
+		return err
+	}
+
+	r.timer = time.AfterFunc(r.config.FlushInterval, r.flushPeriodically)
+	r.active.Store(true)
+
+	return nil
+}
+
+// Stop stops recording and closes the bag file.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.active.Swap(false) {
+		return nil
+	}
+
+	r.timer.Stop()
+
+	return r.closeFile()
+}
+
+// Active reports whether messages are recorded.
+func (r *Recorder) Active() bool {
+	return r != nil && r.active.Load()
+}
+
+// Err returns the last error of writing, recording continues after errors.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// Record writes message, when recording is active.
+func (r *Recorder) Record(direction Direction, topic string, msg *message.Message) {
+	if !r.Active() {
+		return
+	}
+
+	record := NewRecord(direction, topic, msg)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.writer == nil {
+		return
+	}
+
+	if err := r.writer.Write(record); err != nil {
+		r.err = err
+		return
+	}
+
+	if r.writer.Written() >= r.config.MaxFileSize {
+		if err := r.rotate(); err != nil {
+			r.err = err
+		}
+	}
+}
+
+func (r *Recorder) flushPeriodically() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.active.Load() {
+		return
+	}
+
+	if r.writer != nil {
+		if err := r.writer.Flush(); err != nil {
+			r.err = err
+		}
+	}
+
+	r.timer.Reset(r.config.FlushInterval)
+}
+
+// rotate closes the current file and starts a new one, r.mu must be held.
+func (r *Recorder) rotate() error {
+	if err := r.closeFile(); err != nil {
+		r.err = err
+	}
+
+	name := fmt.Sprintf("%s-%s%s", r.config.Prefix, time.Now().UTC().Format(fileTimeLayout), Extension)
+
+	file, err := os.OpenFile(filepath.Join(r.config.Dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("fluxbag: failed to create bag file: %w", err)
+	}
+
+	writer, err := NewWriter(file)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	r.file, r.writer = file, writer
+
+	return r.removeOldFiles()
+}
+
+// closeFile flushes and closes the current file, r.mu must be held.
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.writer.Flush()
+	if closeErr := r.file.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("fluxbag: failed to close bag file: %w", closeErr))
+	}
+
+	r.file, r.writer = nil, nil
+
+	return err
+}
+
+func (r *Recorder) removeOldFiles() error {
+	if r.config.MaxFiles <= 0 {
+		return nil
+	}
+
+	files, err := Files(r.config.Dir, r.config.Prefix)
+	if err != nil {
+		return err
+	}
+
+	for len(files) > r.config.MaxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return fmt.Errorf("fluxbag: failed to remove old bag file: %w", err)
+		}
+
+		files = files[1:]
+	}
+
+	return nil
+}
+
+// fileTimeLayout is a layout of the timestamp in bag file names: <prefix>-<timestamp>.fluxbag.
+const fileTimeLayout = "20060102T150405.000000000"
+
+// Files returns bag files with the prefix in the directory, from the oldest.
+// Only files named exactly <prefix>-<timestamp>.fluxbag match, so prefix "cam" does not match "cam-front" files.
+func Files(dir, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("fluxbag: failed to read bag dir: %w", err)
+	}
+
+	var files []string
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && isBagFile(name, prefix) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+
+	slices.Sort(files)
+
+	return files, nil
+}
+
+// isBagFile reports whether the file name is <prefix>-<timestamp>.fluxbag.
+func isBagFile(name, prefix string) bool {
+	timestamp, ok := strings.CutPrefix(name, prefix+"-")
+	if !ok {
+		return false
+	}
+
+	timestamp, ok = strings.CutSuffix(timestamp, Extension)
+	if !ok {
+		return false
+	}
+
+	_, err := time.Parse(fileTimeLayout, timestamp)
+
+	return err == nil
+}
+
+// RecordingPublisher records every published message.
+type RecordingPublisher struct {
+	pub      message.Publisher
+	recorder *Recorder
+}
+
+func NewRecordingPublisher(pub message.Publisher, recorder *Recorder) *RecordingPublisher {
+	return &RecordingPublisher{pub: pub, recorder: recorder}
+}
+
+func (p *RecordingPublisher) Publish(topic string, messages ...*message.Message) error {
+	for _, msg := range messages {
+		p.recorder.Record(DirectionOut, topic, msg)
+	}
+
+	return p.pub.Publish(topic, messages...) //nolint:wrapcheck
+}
+
+func (p *RecordingPublisher) Close() error {
+	return p.pub.Close() //nolint:wrapcheck
+}
+
+// Middleware records every message handled by the router.
+func (r *Recorder) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		r.Record(DirectionIn, message.SubscribeTopicFromCtx(msg.Context()), msg)
+		return h(msg)
+	}
+}
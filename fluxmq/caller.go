@@ -8,6 +8,8 @@ import (
 	"github.com/nats-io/nats.go"
 
 	wants "github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
+
+	"github.com/flux-agi/flux_go/fluxtrace"
 )
 
 type Caller interface {
@@ -19,12 +21,15 @@ type NatsCallerConfig struct {
 	URL         string
 	Marshaler   wants.Marshaler
 	Unmarshaler wants.Unmarshaler
+	// Tracer creates client spans of calls, fluxtrace.Default is used when nil.
+	Tracer *fluxtrace.Tracer
 }
 
 type NatsCaller struct {
 	conn        *nats.Conn
 	marshaler   wants.Marshaler
 	unmarshaler wants.Unmarshaler
+	tracer      *fluxtrace.Tracer
 	// TODO: add logging via watermill.LoggerAdapter
 }
 
@@ -54,14 +59,32 @@ func NewNatsCaller(
 		cfg.Unmarshaler = marshaller
 	}
 
+	if cfg.Tracer == nil {
+		cfg.Tracer = fluxtrace.Default()
+	}
+
 	return &NatsCaller{
 		conn:        conn,
 		marshaler:   cfg.Marshaler,
 		unmarshaler: cfg.Unmarshaler,
+		tracer:      cfg.Tracer,
 	}, nil
 }
 
 func (nc *NatsCaller) Call(ctx context.Context, topic string, request *message.Message) (*message.Message, error) {
+	ctx, span := nc.tracer.Start(ctx, "fluxmq.call "+topic, fluxtrace.SpanKindClient)
+	defer span.End()
+
+	span.SetAttribute("flux.topic", topic)
+	fluxtrace.Inject(ctx, request)
+
+	response, err := nc.call(ctx, topic, request)
+	span.SetError(err)
+
+	return response, err
+}
+
+func (nc *NatsCaller) call(ctx context.Context, topic string, request *message.Message) (*message.Message, error) {
 	natsRequest, err := nc.marshaler.Marshal(topic, request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal nats message: %w", err)
@@ -80,6 +103,11 @@ func (nc *NatsCaller) Call(ctx context.Context, topic string, request *message.M
 	return response, nil
 }
 
+// IsConnected returns whether caller is connected to NATS.
+func (nc *NatsCaller) IsConnected() bool {
+	return nc.conn.IsConnected()
+}
+
 func (nc *NatsCaller) Close() error {
 	// TODO: should it close gracefully, like in watermill nats subscriber?
 
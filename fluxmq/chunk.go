@@ -0,0 +1,403 @@
+package fluxmq
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Metadata keys of chunks. Transfer id is the uuid of the original message.
+const (
+	MetadataChunkTransfer = "flux_chunk_transfer"
+	MetadataChunkIndex    = "flux_chunk_index"
+	MetadataChunkCount    = "flux_chunk_count"
+	MetadataChunkSize     = "flux_chunk_size"
+)
+
+const (
+	// DefaultChunkSize fits into the default NATS max payload of 1 MB with room for headers.
+	DefaultChunkSize        = 512 * 1024
+	DefaultChunkTimeout     = 10 * time.Second
+	DefaultChunkMemoryLimit = 256 * 1024 * 1024
+	// MaxChunkCount is a maximal count of chunks in one transfer, chunks with larger count are dropped.
+	MaxChunkCount = 64 * 1024
+)
+
+// ChunkConfig configures chunked transfer of large payloads.
+type ChunkConfig struct {
+	// ChunkSize is a maximal payload size of one message, larger payloads are split.
+	ChunkSize int
+	// Timeout is a maximal time between the first and the last chunk of transfer.
+	Timeout time.Duration
+	// MemoryLimit is a maximal size of payloads, which are being reassembled by one subscription.
+	// When it is exceeded, the oldest transfers are dropped.
+	MemoryLimit int
+}
+
+func (c ChunkConfig) withDefaults() ChunkConfig {
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = DefaultChunkSize
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultChunkTimeout
+	}
+
+	if c.MemoryLimit <= 0 {
+		c.MemoryLimit = DefaultChunkMemoryLimit
+	}
+
+	return c
+}
+
+// ChunkStats is a snapshot of chunked transfer counters.
+type ChunkStats struct {
+	// Split is a count of messages split into chunks by publisher.
+	Split uint64 `json:"split"`
+	// Reassembled is a count of messages reassembled by subscriber.
+	Reassembled uint64 `json:"reassembled"`
+	// Incomplete is a count of transfers dropped by timeout or memory limit.
+	Incomplete uint64 `json:"incomplete"`
+	// DroppedChunks is a count of invalid chunks and chunks of dropped transfers.
+	DroppedChunks uint64 `json:"dropped_chunks"`
+}
+
+type chunkCounters struct {
+	split         atomic.Uint64
+	reassembled   atomic.Uint64
+	incomplete    atomic.Uint64
+	droppedChunks atomic.Uint64
+}
+
+func (c *chunkCounters) stats() ChunkStats {
+	return ChunkStats{
+		Split:         c.split.Load(),
+		Reassembled:   c.reassembled.Load(),
+		Incomplete:    c.incomplete.Load(),
+		DroppedChunks: c.droppedChunks.Load(),
+	}
+}
+
+// ChunkingPublisher splits payloads larger than chunk size into sequenced chunks.
+// Messages are reassembled by ChunkingSubscriber.
+type ChunkingPublisher struct {
+	pub      message.Publisher
+	config   ChunkConfig
+	counters *chunkCounters
+}
+
+func NewChunkingPublisher(pub message.Publisher, config ChunkConfig) *ChunkingPublisher {
+	return &ChunkingPublisher{
+		pub:      pub,
+		config:   config.withDefaults(),
+		counters: new(chunkCounters),
+	}
+}
+
+func (p *ChunkingPublisher) Publish(topic string, messages ...*message.Message) error {
+	out := make([]*message.Message, 0, len(messages))
+
+	for _, msg := range messages {
+		if len(msg.Payload) <= p.config.ChunkSize {
+			out = append(out, msg)
+			continue
+		}
+
+		out = append(out, p.split(msg)...)
+		p.counters.split.Add(1)
+	}
+
+	if err := p.pub.Publish(topic, out...); err != nil {
+		return fmt.Errorf("could not publish chunks: %w", err)
+	}
+
+	return nil
+}
+
+func (p *ChunkingPublisher) split(msg *message.Message) []*message.Message {
+	size := len(msg.Payload)
+	count := (size + p.config.ChunkSize - 1) / p.config.ChunkSize
+
+	chunks := make([]*message.Message, 0, count)
+	for i := range count {
+		end := min((i+1)*p.config.ChunkSize, size)
+
+		chunk := message.NewMessage(watermill.NewUUID(), msg.Payload[i*p.config.ChunkSize:end])
+		chunk.Metadata = maps.Clone(msg.Metadata)
+		if chunk.Metadata == nil {
+			chunk.Metadata = make(message.Metadata)
+		}
+
+		chunk.Metadata.Set(MetadataChunkTransfer, msg.UUID)
+		chunk.Metadata.Set(MetadataChunkIndex, strconv.Itoa(i))
+		chunk.Metadata.Set(MetadataChunkCount, strconv.Itoa(count))
+		chunk.Metadata.Set(MetadataChunkSize, strconv.Itoa(size))
+
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+func (p *ChunkingPublisher) Stats() ChunkStats {
+	return p.counters.stats()
+}
+
+func (p *ChunkingPublisher) Close() error {
+	return p.pub.Close() //nolint:wrapcheck
+}
+
+// ChunkingSubscriber reassembles chunks published by ChunkingPublisher.
+// Messages without chunk metadata are passed as is.
+//
+// Chunks are acknowledged, when they are buffered, so a reassembled message is not redelivered.
+type ChunkingSubscriber struct {
+	sub      message.Subscriber
+	config   ChunkConfig
+	counters *chunkCounters
+}
+
+func NewChunkingSubscriber(sub message.Subscriber, config ChunkConfig) *ChunkingSubscriber {
+	return &ChunkingSubscriber{
+		sub:      sub,
+		config:   config.withDefaults(),
+		counters: new(chunkCounters),
+	}
+}
+
+func (s *ChunkingSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	in, err := s.sub.Subscribe(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("could not subscribe: %w", err)
+	}
+
+	out := make(chan *message.Message)
+	assembler := newChunkAssembler(s.config, s.counters)
+
+	go func() {
+		defer close(out)
+
+		ticker := time.NewTicker(s.config.Timeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ticker.C:
+				assembler.expire(time.Now())
+
+			case msg, ok := <-in:
+				if !ok {
+					return
+				}
+
+				if msg.Metadata.Get(MetadataChunkTransfer) == "" {
+					if !forward(ctx, out, msg) {
+						return
+					}
+					continue
+				}
+
+				assembled := assembler.add(msg)
+				msg.Ack()
+
+				if assembled == nil {
+					continue
+				}
+
+				assembled.SetContext(msg.Context())
+				if !forward(ctx, out, assembled) {
+					return
+				}
+
+				// keep order of messages, like the wrapped subscriber does
+				select {
+				case <-assembled.Acked():
+				case <-assembled.Nacked():
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func forward(ctx context.Context, out chan<- *message.Message, msg *message.Message) bool {
+	select {
+	case out <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *ChunkingSubscriber) Stats() ChunkStats {
+	return s.counters.stats()
+}
+
+func (s *ChunkingSubscriber) Close() error {
+	return s.sub.Close() //nolint:wrapcheck
+}
+
+type chunkTransfer struct {
+	started  time.Time
+	size     int
+	received int
+	// bytes is a total payload size of received chunks, it never exceeds size.
+	bytes    int
+	chunks   [][]byte
+	metadata message.Metadata
+}
+
+// chunkAssembler keeps transfers of one subscription.
+type chunkAssembler struct {
+	config   ChunkConfig
+	counters *chunkCounters
+
+	mu        sync.Mutex
+	transfers map[string]*chunkTransfer
+	order     []string
+}
+
+func newChunkAssembler(config ChunkConfig, counters *chunkCounters) *chunkAssembler {
+	return &chunkAssembler{
+		config:    config,
+		counters:  counters,
+		mu:        sync.Mutex{},
+		transfers: make(map[string]*chunkTransfer),
+		order:     nil,
+	}
+}
+
+// add buffers the chunk, it returns reassembled message, when all chunks are received.
+//
+// Chunks are not trusted: count must not exceed MaxChunkCount and declared size, as chunks are not empty,
+// and transfer is dropped, when its chunks do not add up to the declared size.
+func (a *chunkAssembler) add(chunk *message.Message) *message.Message {
+	id := chunk.Metadata.Get(MetadataChunkTransfer)
+	index, indexErr := strconv.Atoi(chunk.Metadata.Get(MetadataChunkIndex))
+	count, countErr := strconv.Atoi(chunk.Metadata.Get(MetadataChunkCount))
+	size, sizeErr := strconv.Atoi(chunk.Metadata.Get(MetadataChunkSize))
+
+	if indexErr != nil || countErr != nil || sizeErr != nil ||
+		index < 0 || index >= count || count > MaxChunkCount || count > size || size > a.config.MemoryLimit {
+		a.counters.droppedChunks.Add(1)
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	transfer, ok := a.transfers[id]
+	if !ok {
+		a.evict(size)
+
+		transfer = &chunkTransfer{
+			started:  time.Now(),
+			size:     size,
+			received: 0,
+			bytes:    0,
+			chunks:   make([][]byte, count),
+			metadata: chunk.Metadata,
+		}
+		a.transfers[id] = transfer
+		a.order = append(a.order, id)
+	}
+
+	if len(transfer.chunks) != count || transfer.size != size || transfer.chunks[index] != nil {
+		a.counters.droppedChunks.Add(1)
+		return nil
+	}
+
+	transfer.chunks[index] = chunk.Payload
+	transfer.received++
+	transfer.bytes += len(chunk.Payload)
+
+	if transfer.bytes > transfer.size || (transfer.received == count && transfer.bytes != transfer.size) {
+		// chunks do not match the declared size, the transfer is corrupted
+		a.remove(id)
+		a.counters.droppedChunks.Add(1)
+		a.counters.incomplete.Add(1)
+
+		return nil
+	}
+
+	if transfer.received < count {
+		return nil
+	}
+
+	a.remove(id)
+	a.counters.reassembled.Add(1)
+
+	payload := make([]byte, 0, transfer.size)
+	for _, part := range transfer.chunks {
+		payload = append(payload, part...)
+	}
+
+	msg := message.NewMessage(id, payload)
+	msg.Metadata = maps.Clone(transfer.metadata)
+	for _, key := range []string{MetadataChunkTransfer, MetadataChunkIndex, MetadataChunkCount, MetadataChunkSize} {
+		delete(msg.Metadata, key)
+	}
+
+	return msg
+}
+
+// evict drops the oldest transfers, until the new transfer of given size fits into memory limit.
+// a.mu must be held.
+func (a *chunkAssembler) evict(size int) {
+	for len(a.order) > 0 && a.reserved()+size > a.config.MemoryLimit {
+		a.remove(a.order[0])
+		a.counters.incomplete.Add(1)
+	}
+}
+
+// reserved returns total size of transfers in progress, a.mu must be held.
+func (a *chunkAssembler) reserved() int {
+	total := 0
+	for _, transfer := range a.transfers {
+		total += transfer.size
+	}
+
+	return total
+}
+
+// expire drops transfers, which were not completed within timeout.
+func (a *chunkAssembler) expire(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, id := range append([]string(nil), a.order...) {
+		if now.Sub(a.transfers[id].started) > a.config.Timeout {
+			a.remove(id)
+			a.counters.incomplete.Add(1)
+		}
+	}
+}
+
+// remove forgets the transfer, a.mu must be held.
+func (a *chunkAssembler) remove(id string) {
+	if _, ok := a.transfers[id]; !ok {
+		return
+	}
+
+	delete(a.transfers, id)
+
+	for i, other := range a.order {
+		if other == id {
+			a.order = append(a.order[:i], a.order[i+1:]...)
+			break
+		}
+	}
+}
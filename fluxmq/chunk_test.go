@@ -0,0 +1,181 @@
+package fluxmq
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func newChunk(transfer string, index, count, size int, payload string) *message.Message {
+	chunk := message.NewMessage(transfer+"-"+strconv.Itoa(index), []byte(payload))
+	chunk.Metadata.Set(MetadataChunkTransfer, transfer)
+	chunk.Metadata.Set(MetadataChunkIndex, strconv.Itoa(index))
+	chunk.Metadata.Set(MetadataChunkCount, strconv.Itoa(count))
+	chunk.Metadata.Set(MetadataChunkSize, strconv.Itoa(size))
+
+	return chunk
+}
+
+func TestChunkAssemblerAdd(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config ChunkConfig
+		chunks []*message.Message
+		// want is a payload of the reassembled message, nil when nothing is reassembled
+		want      []byte
+		wantStats ChunkStats
+	}{
+		{
+			name:      "in order",
+			config:    ChunkConfig{ChunkSize: 0, Timeout: 0, MemoryLimit: 0},
+			chunks:    []*message.Message{newChunk("t", 0, 2, 6, "abc"), newChunk("t", 1, 2, 6, "def")},
+			want:      []byte("abcdef"),
+			wantStats: ChunkStats{Split: 0, Reassembled: 1, Incomplete: 0, DroppedChunks: 0},
+		},
+		{
+			name:   "out of order with duplicate",
+			config: ChunkConfig{ChunkSize: 0, Timeout: 0, MemoryLimit: 0},
+			chunks: []*message.Message{
+				newChunk("t", 2, 3, 5, "e"),
+				newChunk("t", 2, 3, 5, "e"),
+				newChunk("t", 0, 3, 5, "ab"),
+				newChunk("t", 1, 3, 5, "cd"),
+			},
+			want:      []byte("abcde"),
+			wantStats: ChunkStats{Split: 0, Reassembled: 1, Incomplete: 0, DroppedChunks: 1},
+		},
+		{
+			name:   "invalid metadata",
+			config: ChunkConfig{ChunkSize: 0, Timeout: 0, MemoryLimit: 0},
+			chunks: []*message.Message{
+				newChunk("t", 2, 2, 6, "abc"),
+				newChunk("t", -1, 2, 6, "abc"),
+				newChunk("t", 0, MaxChunkCount+1, 2*MaxChunkCount, "abc"),
+				newChunk("t", 0, 10, 6, "abc"),
+			},
+			want:      nil,
+			wantStats: ChunkStats{Split: 0, Reassembled: 0, Incomplete: 0, DroppedChunks: 4},
+		},
+		{
+			name:      "size over memory limit",
+			config:    ChunkConfig{ChunkSize: 0, Timeout: 0, MemoryLimit: 4},
+			chunks:    []*message.Message{newChunk("t", 0, 2, 6, "abc")},
+			want:      nil,
+			wantStats: ChunkStats{Split: 0, Reassembled: 0, Incomplete: 0, DroppedChunks: 1},
+		},
+		{
+			name:   "chunks exceed declared size",
+			config: ChunkConfig{ChunkSize: 0, Timeout: 0, MemoryLimit: 0},
+			chunks: []*message.Message{
+				newChunk("t", 0, 3, 4, "abcdef"),
+				newChunk("t", 1, 3, 4, "g"),
+			},
+			want:      nil,
+			wantStats: ChunkStats{Split: 0, Reassembled: 0, Incomplete: 1, DroppedChunks: 1},
+		},
+		{
+			name:   "chunks smaller than declared size",
+			config: ChunkConfig{ChunkSize: 0, Timeout: 0, MemoryLimit: 0},
+			chunks: []*message.Message{
+				newChunk("t", 0, 2, 10, "ab"),
+				newChunk("t", 1, 2, 10, "cd"),
+			},
+			want:      nil,
+			wantStats: ChunkStats{Split: 0, Reassembled: 0, Incomplete: 1, DroppedChunks: 1},
+		},
+		{
+			name:   "oldest transfer is evicted by memory limit",
+			config: ChunkConfig{ChunkSize: 0, Timeout: 0, MemoryLimit: 10},
+			chunks: []*message.Message{
+				newChunk("old", 0, 2, 6, "abc"),
+				newChunk("new", 0, 2, 6, "ghi"),
+				newChunk("new", 1, 2, 6, "jkl"),
+			},
+			want:      []byte("ghijkl"),
+			wantStats: ChunkStats{Split: 0, Reassembled: 1, Incomplete: 1, DroppedChunks: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			counters := new(chunkCounters)
+			assembler := newChunkAssembler(tt.config.withDefaults(), counters)
+
+			var got *message.Message
+			for _, chunk := range tt.chunks {
+				if msg := assembler.add(chunk); msg != nil {
+					got = msg
+				}
+			}
+
+			switch {
+			case tt.want == nil && got != nil:
+				t.Fatalf("reassembled %q, want nothing", got.Payload)
+			case tt.want != nil && got == nil:
+				t.Fatalf("nothing reassembled, want %q", tt.want)
+			case tt.want != nil && !bytes.Equal(got.Payload, tt.want):
+				t.Fatalf("reassembled %q, want %q", got.Payload, tt.want)
+			}
+
+			if got != nil && got.Metadata.Get(MetadataChunkIndex) != "" {
+				t.Fatal("chunk metadata is left in reassembled message")
+			}
+
+			if stats := counters.stats(); stats != tt.wantStats {
+				t.Fatalf("stats %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestChunkAssemblerExpire(t *testing.T) {
+	t.Parallel()
+
+	counters := new(chunkCounters)
+	assembler := newChunkAssembler(ChunkConfig{ChunkSize: 0, Timeout: time.Minute, MemoryLimit: 0}.withDefaults(), counters)
+
+	if msg := assembler.add(newChunk("t", 0, 2, 6, "abc")); msg != nil {
+		t.Fatal("transfer is reassembled from one chunk")
+	}
+
+	assembler.expire(time.Now())
+	if counters.stats().Incomplete != 0 {
+		t.Fatal("transfer expired before timeout")
+	}
+
+	assembler.expire(time.Now().Add(2 * time.Minute))
+	if counters.stats().Incomplete != 1 || assembler.reserved() != 0 {
+		t.Fatal("transfer is not expired after timeout")
+	}
+}
+
+func TestChunkingPublisherSplit(t *testing.T) {
+	t.Parallel()
+
+	publisher := NewChunkingPublisher(nil, ChunkConfig{ChunkSize: 4, Timeout: 0, MemoryLimit: 0})
+	assembler := newChunkAssembler(ChunkConfig{ChunkSize: 4, Timeout: 0, MemoryLimit: 0}.withDefaults(), new(chunkCounters))
+
+	msg := message.NewMessage("id", []byte("0123456789"))
+	msg.Metadata.Set("key", "value")
+
+	chunks := publisher.split(msg)
+	if len(chunks) != 3 {
+		t.Fatalf("split into %d chunks, want 3", len(chunks))
+	}
+
+	var got *message.Message
+	for i := len(chunks) - 1; i >= 0; i-- {
+		got = assembler.add(chunks[i])
+	}
+
+	if got == nil || got.UUID != msg.UUID || !bytes.Equal(got.Payload, msg.Payload) || got.Metadata.Get("key") != "value" {
+		t.Fatalf("reassembled message %+v does not match the original", got)
+	}
+}
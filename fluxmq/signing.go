@@ -0,0 +1,303 @@
+package fluxmq
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/nats-io/nkeys"
+)
+
+// Metadata keys of signed messages.
+const (
+	MetadataSigner    = "flux_signer"
+	MetadataSignature = "flux_signature"
+	MetadataSignedAt  = "flux_signed_at"
+)
+
+// DefaultSignatureMaxAge is a maximal age of signed messages, when SigningConfig.MaxAge is zero.
+const DefaultSignatureMaxAge = 5 * time.Minute
+
+var (
+	ErrUnsignedMessage  = errors.New("fluxmq: message is not signed")
+	ErrUntrustedSigner  = errors.New("fluxmq: untrusted signer")
+	ErrInvalidSignature = errors.New("fluxmq: invalid signature")
+	ErrExpiredSignature = errors.New("fluxmq: expired signature")
+)
+
+// SigningConfig configures ed25519 signing and verification of messages.
+type SigningConfig struct {
+	// KeyPair signs published messages, its public key is trusted as well.
+	KeyPair nkeys.KeyPair
+	// TrustedKeys are public keys of the manager and services, which messages are accepted.
+	TrustedKeys []string
+	// Match selects topics, which messages are signed and verified. Nil matches all topics.
+	Match func(topic string) bool
+	// MaxAge rejects messages signed earlier, it protects from replaying captured messages.
+	// Zero means DefaultSignatureMaxAge, negative disables the check.
+	MaxAge time.Duration
+}
+
+func (c SigningConfig) match(topic string) bool {
+	return c.Match == nil || c.Match(topic)
+}
+
+func (c SigningConfig) maxAge() time.Duration {
+	if c.MaxAge == 0 {
+		return DefaultSignatureMaxAge
+	}
+
+	return c.MaxAge
+}
+
+// signedData returns signed bytes, signature covers the topic, signing time, metadata and payload.
+//
+// Metadata is encoded canonically: length-prefixed keys and values sorted by key,
+// keys of the signature itself are excluded.
+func signedData(topic, signedAt string, metadata message.Metadata, payload []byte) []byte {
+	var buf bytes.Buffer
+
+	writeField := func(field string) {
+		buf.Write(binary.AppendUvarint(nil, uint64(len(field))))
+		buf.WriteString(field)
+	}
+
+	writeField(topic)
+	writeField(signedAt)
+
+	keys := slices.Sorted(maps.Keys(metadata))
+	for _, key := range keys {
+		if key == MetadataSigner || key == MetadataSignature || key == MetadataSignedAt {
+			continue
+		}
+
+		writeField(key)
+		writeField(metadata[key])
+	}
+
+	buf.WriteByte(0)
+	buf.Write(payload)
+
+	return buf.Bytes()
+}
+
+// Sign signs message published into the topic.
+func Sign(kp nkeys.KeyPair, topic string, msg *message.Message) error {
+	signer, err := kp.PublicKey()
+	if err != nil {
+		return fmt.Errorf("could not get public key: %w", err)
+	}
+
+	signedAt := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	signature, err := kp.Sign(signedData(topic, signedAt, msg.Metadata, msg.Payload))
+	if err != nil {
+		return fmt.Errorf("could not sign message: %w", err)
+	}
+
+	msg.Metadata.Set(MetadataSigner, signer)
+	msg.Metadata.Set(MetadataSignedAt, signedAt)
+	msg.Metadata.Set(MetadataSignature, base64.RawStdEncoding.EncodeToString(signature))
+
+	return nil
+}
+
+// Verifier checks signatures of messages against trusted public keys.
+type Verifier struct {
+	trusted map[string]nkeys.KeyPair
+	maxAge  time.Duration
+}
+
+// NewVerifier creates verifier of messages signed by trusted keys, non-positive maxAge disables the age check.
+func NewVerifier(trustedKeys []string, maxAge time.Duration) (*Verifier, error) {
+	trusted := make(map[string]nkeys.KeyPair, len(trustedKeys))
+
+	for _, key := range trustedKeys {
+		kp, err := nkeys.FromPublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted key %q: %w", key, err)
+		}
+
+		trusted[key] = kp
+	}
+
+	return &Verifier{trusted: trusted, maxAge: maxAge}, nil
+}
+
+// Verify checks, that message received from the topic is signed by a trusted key.
+func (v *Verifier) Verify(topic string, msg *message.Message) error {
+	signer := msg.Metadata.Get(MetadataSigner)
+	if signer == "" {
+		return ErrUnsignedMessage
+	}
+
+	kp, ok := v.trusted[signer]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUntrustedSigner, signer)
+	}
+
+	signature, err := base64.RawStdEncoding.DecodeString(msg.Metadata.Get(MetadataSignature))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	signedAt := msg.Metadata.Get(MetadataSignedAt)
+	if err := kp.Verify(signedData(topic, signedAt, msg.Metadata, msg.Payload), signature); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	if v.maxAge > 0 {
+		nanos, err := strconv.ParseInt(signedAt, 10, 64)
+		if err != nil || time.Since(time.Unix(0, nanos)) > v.maxAge {
+			return ErrExpiredSignature
+		}
+	}
+
+	return nil
+}
+
+// SigningPublisher signs messages of matched topics.
+type SigningPublisher struct {
+	pub    message.Publisher
+	config SigningConfig
+}
+
+func NewSigningPublisher(pub message.Publisher, config SigningConfig) (*SigningPublisher, error) {
+	if config.KeyPair == nil {
+		return nil, errors.New("fluxmq: key pair is required for signing")
+	}
+
+	return &SigningPublisher{pub: pub, config: config}, nil
+}
+
+func (p *SigningPublisher) Publish(topic string, messages ...*message.Message) error {
+	if p.config.match(topic) {
+		for _, msg := range messages {
+			if err := Sign(p.config.KeyPair, topic, msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.pub.Publish(topic, messages...) //nolint:wrapcheck
+}
+
+func (p *SigningPublisher) Close() error {
+	return p.pub.Close() //nolint:wrapcheck
+}
+
+// VerifyStats is a snapshot of verification counters.
+type VerifyStats struct {
+	Verified uint64 `json:"verified"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// VerifyingSubscriber drops messages of matched topics, which are not signed by trusted keys,
+// before they are dispatched to handlers. Dropped messages are acknowledged.
+type VerifyingSubscriber struct {
+	sub      message.Subscriber
+	config   SigningConfig
+	verifier *Verifier
+	onReject func(topic string, msg *message.Message, err error)
+
+	verified atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// NewVerifyingSubscriber creates subscriber, onReject is called with rejected messages and can be nil.
+func NewVerifyingSubscriber(
+	sub message.Subscriber,
+	config SigningConfig,
+	onReject func(topic string, msg *message.Message, err error),
+) (*VerifyingSubscriber, error) {
+	trusted := config.TrustedKeys
+
+	if config.KeyPair != nil {
+		own, err := config.KeyPair.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("could not get public key: %w", err)
+		}
+
+		trusted = append([]string{own}, trusted...)
+	}
+
+	verifier, err := NewVerifier(trusted, config.maxAge())
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifyingSubscriber{
+		sub:      sub,
+		config:   config,
+		verifier: verifier,
+		onReject: onReject,
+		verified: atomic.Uint64{},
+		rejected: atomic.Uint64{},
+	}, nil
+}
+
+func (s *VerifyingSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	in, err := s.sub.Subscribe(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("could not subscribe: %w", err)
+	}
+
+	if !s.config.match(topic) {
+		return in, nil
+	}
+
+	out := make(chan *message.Message)
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case msg, ok := <-in:
+				if !ok {
+					return
+				}
+
+				if err := s.verifier.Verify(topic, msg); err != nil {
+					s.rejected.Add(1)
+					if s.onReject != nil {
+						s.onReject(topic, msg, err)
+					}
+					msg.Ack()
+					continue
+				}
+
+				s.verified.Add(1)
+
+				if !forward(ctx, out, msg) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *VerifyingSubscriber) Stats() VerifyStats {
+	return VerifyStats{
+		Verified: s.verified.Load(),
+		Rejected: s.rejected.Load(),
+	}
+}
+
+func (s *VerifyingSubscriber) Close() error {
+	return s.sub.Close() //nolint:wrapcheck
+}
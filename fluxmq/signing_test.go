@@ -0,0 +1,177 @@
+package fluxmq
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/nats-io/nkeys"
+)
+
+func TestVerifierVerify(t *testing.T) {
+	t.Parallel()
+
+	signer, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stranger, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted, err := signer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// sign signs message, nil leaves it unsigned
+		sign    nkeys.KeyPair
+		tamper  func(msg *message.Message)
+		topic   string
+		maxAge  time.Duration
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			sign:    signer,
+			tamper:  func(_ *message.Message) {},
+			topic:   "service.a.config",
+			maxAge:  time.Minute,
+			wantErr: nil,
+		},
+		{
+			name:    "unsigned",
+			sign:    nil,
+			tamper:  func(_ *message.Message) {},
+			topic:   "service.a.config",
+			maxAge:  time.Minute,
+			wantErr: ErrUnsignedMessage,
+		},
+		{
+			name:    "untrusted signer",
+			sign:    stranger,
+			tamper:  func(_ *message.Message) {},
+			topic:   "service.a.config",
+			maxAge:  time.Minute,
+			wantErr: ErrUntrustedSigner,
+		},
+		{
+			name:    "replayed into other topic",
+			sign:    signer,
+			tamper:  func(_ *message.Message) {},
+			topic:   "service.b.config",
+			maxAge:  time.Minute,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "tampered payload",
+			sign:    signer,
+			tamper:  func(msg *message.Message) { msg.Payload = []byte(`{"stop":true}`) },
+			topic:   "service.a.config",
+			maxAge:  time.Minute,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "tampered metadata",
+			sign:    signer,
+			tamper:  func(msg *message.Message) { msg.Metadata.Set("flux_source_node", "other") },
+			topic:   "service.a.config",
+			maxAge:  time.Minute,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "added metadata",
+			sign:    signer,
+			tamper:  func(msg *message.Message) { msg.Metadata.Set("flux_encoding", "gzip") },
+			topic:   "service.a.config",
+			maxAge:  time.Minute,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "expired",
+			sign: signer,
+			tamper: func(msg *message.Message) {
+				// signing time is covered by signature, so expired message must be signed in the past
+				msg.Metadata.Set(MetadataSignedAt, strconv.FormatInt(time.Now().Add(-time.Hour).UnixNano(), 10))
+			},
+			topic:   "service.a.config",
+			maxAge:  time.Minute,
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			verifier, err := NewVerifier([]string{trusted}, tt.maxAge)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg := message.NewMessage("id", []byte(`{"stop":false}`))
+			msg.Metadata.Set("flux_source_node", "node")
+
+			if tt.sign != nil {
+				if err := Sign(tt.sign, "service.a.config", msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tt.tamper(msg)
+
+			if err := verifier.Verify(tt.topic, msg); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifierMaxAge(t *testing.T) {
+	t.Parallel()
+
+	signer, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted, err := signer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.NewMessage("id", []byte("payload"))
+	if err := Sign(signer, "topic", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	for _, tt := range []struct {
+		maxAge  time.Duration
+		wantErr error
+	}{
+		{maxAge: time.Millisecond, wantErr: ErrExpiredSignature},
+		{maxAge: time.Minute, wantErr: nil},
+		{maxAge: -1, wantErr: nil},
+	} {
+		verifier, err := NewVerifier([]string{trusted}, tt.maxAge)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := verifier.Verify("topic", msg); !errors.Is(err, tt.wantErr) {
+			t.Fatalf("max age %s: error %v, want %v", tt.maxAge, err, tt.wantErr)
+		}
+	}
+
+	if got := (SigningConfig{KeyPair: nil, TrustedKeys: nil, Match: nil, MaxAge: 0}).maxAge(); got != DefaultSignatureMaxAge {
+		t.Fatalf("default max age %s, want %s", got, DefaultSignatureMaxAge)
+	}
+}
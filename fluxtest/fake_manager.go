@@ -7,6 +7,8 @@ import (
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
+
+	"github.com/flux-agi/flux_go/flux"
 )
 
 type FakeManager struct {
@@ -44,7 +46,7 @@ func (f *FakeManager) Run(ctx context.Context) func() {
 func (f *FakeManager) run(ctx context.Context, service string, config any) {
 	messages, err := f.sub.Subscribe(
 		ctx,
-		fmt.Sprintf("service/%s/get_config", service),
+		flux.NewTopics(service).RequestConfig(),
 	)
 	if err != nil {
 		panic(fmt.Errorf("could not subscribe to config: %w", err))
@@ -60,7 +62,7 @@ func (f *FakeManager) run(ctx context.Context, service string, config any) {
 				}
 
 				err = f.pub.Publish(
-					fmt.Sprintf("service/%s/set_config", service),
+					flux.NewTopics(service).ResponseConfig(),
 					message.NewMessage(watermill.NewUUID(), data),
 				)
 				if err != nil {
@@ -0,0 +1,55 @@
+package fluxtest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+
+	"github.com/flux-agi/flux_go/fluxbag"
+)
+
+// NewReplayPubSub returns in-memory pub/sub, where publishing blocks until the subscriber acknowledges
+// the message. Replaying a bag through it handles messages one by one, so regression tests are deterministic.
+func NewReplayPubSub() *gochannel.GoChannel {
+	return gochannel.NewGoChannel(gochannel.Config{
+		OutputChannelBuffer:            0,
+		Persistent:                     false,
+		BlockPublishUntilSubscriberAck: true,
+	}, watermill.NopLogger{})
+}
+
+// ReplayBag publishes messages received by the recorded service as fast as possible.
+// Config can narrow topics and offsets, or remap topics to the nodes under test.
+func ReplayBag(ctx context.Context, pub message.Publisher, config fluxbag.PlayerConfig, paths ...string) error {
+	config.Speed = 0
+	if len(config.Directions) == 0 {
+		config.Directions = []fluxbag.Direction{fluxbag.DirectionIn}
+	}
+
+	if _, err := fluxbag.NewPlayer(pub, config).PlayFiles(ctx, paths...); err != nil {
+		return fmt.Errorf("could not replay bag: %w", err)
+	}
+
+	return nil
+}
+
+// RecordedOutputs returns messages published by the recorded service,
+// they are expected outputs of the replayed service.
+func RecordedOutputs(paths ...string) ([]fluxbag.Record, error) {
+	records, err := fluxbag.ReadFiles(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bag: %w", err)
+	}
+
+	outputs := make([]fluxbag.Record, 0, len(records))
+	for _, record := range records {
+		if record.Direction == fluxbag.DirectionOut {
+			outputs = append(outputs, record)
+		}
+	}
+
+	return outputs, nil
+}
@@ -0,0 +1,166 @@
+package fluxtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+
+	"github.com/flux-agi/flux_go/fluxbag"
+)
+
+// writeBagFile writes records into a bag file of the test directory.
+func writeBagFile(t *testing.T, records []fluxbag.Record) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.fluxbag")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer, err := fluxbag.NewWriter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func testRecords() []fluxbag.Record {
+	start := time.Unix(1700000000, 0)
+
+	record := func(i int, direction fluxbag.Direction, topic string) fluxbag.Record {
+		return fluxbag.Record{
+			Time:      start.Add(time.Duration(i) * time.Second),
+			Direction: direction,
+			Topic:     topic,
+			UUID:      string(rune('0' + i)),
+			Metadata:  message.Metadata{},
+			Payload:   []byte{byte('0' + i)},
+		}
+	}
+
+	return []fluxbag.Record{
+		record(0, fluxbag.DirectionIn, "node.a.in"),
+		record(1, fluxbag.DirectionOut, "node.b.out"),
+		record(2, fluxbag.DirectionIn, "node.c.in"),
+		record(3, fluxbag.DirectionOut, "node.b.out"),
+	}
+}
+
+func TestReplayBag(t *testing.T) {
+	t.Parallel()
+
+	path := writeBagFile(t, testRecords())
+
+	tests := []struct {
+		name   string
+		config fluxbag.PlayerConfig
+		want   []string
+	}{
+		{
+			name:   "received messages",
+			config: fluxbag.PlayerConfig{},
+			want:   []string{"0", "2"},
+		},
+		{
+			name:   "topics",
+			config: fluxbag.PlayerConfig{Topics: []string{"node.c.*"}},
+			want:   []string{"2"},
+		},
+		{
+			name:   "directions",
+			config: fluxbag.PlayerConfig{Directions: []fluxbag.Direction{fluxbag.DirectionOut}},
+			want:   []string{"1", "3"},
+		},
+		{
+			name:   "offsets",
+			config: fluxbag.PlayerConfig{End: time.Second},
+			want:   []string{"0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pubSub := NewReplayPubSub()
+			defer pubSub.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			messages, err := pubSub.Subscribe(ctx, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// all records are remapped into one topic to check their order
+			config := tt.config
+			config.Remap = func(string) string { return "test" }
+
+			// speed is ignored, records 1s apart are replayed at once
+			config.Speed = 1
+
+			var got []string
+			done := make(chan struct{})
+
+			go func() {
+				defer close(done)
+
+				for msg := range messages {
+					got = append(got, msg.UUID)
+					msg.Ack()
+				}
+			}()
+
+			if err := ReplayBag(ctx, pubSub, config, path); err != nil {
+				t.Fatal(err)
+			}
+
+			cancel()
+			<-done
+
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("replayed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordedOutputs(t *testing.T) {
+	t.Parallel()
+
+	path := writeBagFile(t, testRecords())
+
+	outputs, err := RecordedOutputs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uuids := make([]string, 0, len(outputs))
+	for _, record := range outputs {
+		uuids = append(uuids, record.UUID)
+	}
+
+	if want := []string{"1", "3"}; !slices.Equal(uuids, want) {
+		t.Fatalf("outputs %v, want %v", uuids, want)
+	}
+}
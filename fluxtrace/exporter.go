@@ -0,0 +1,86 @@
+package fluxtrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SpanData is a finished span passed to the exporter.
+type SpanData struct {
+	Name         string            `json:"name"`
+	Kind         SpanKind          `json:"kind"`
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Flags        byte              `json:"flags"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	Duration     time.Duration     `json:"duration_ns"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// Exporter receives finished spans.
+type Exporter interface {
+	ExportSpan(span SpanData) error
+	Close() error
+}
+
+// JSONLinesExporter writes every finished span as a json object on its own line.
+type JSONLinesExporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewJSONLinesExporter creates exporter, which writes spans into w.
+// When w is io.Closer, it is closed by Close.
+func NewJSONLinesExporter(w io.Writer) *JSONLinesExporter {
+	closer, _ := w.(io.Closer)
+
+	return &JSONLinesExporter{
+		mu:      sync.Mutex{},
+		encoder: json.NewEncoder(w),
+		closer:  closer,
+	}
+}
+
+// OpenJSONLinesExporter creates exporter, which appends spans to the file.
+func OpenJSONLinesExporter(path string) (*JSONLinesExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("fluxtrace: failed to open trace file: %w", err)
+	}
+
+	return NewJSONLinesExporter(file), nil
+}
+
+func (e *JSONLinesExporter) ExportSpan(span SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.encoder.Encode(span); err != nil {
+		return fmt.Errorf("fluxtrace: failed to write span: %w", err)
+	}
+
+	return nil
+}
+
+func (e *JSONLinesExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closer == nil {
+		return nil
+	}
+
+	if err := e.closer.Close(); err != nil {
+		return fmt.Errorf("fluxtrace: failed to close writer: %w", err)
+	}
+
+	return nil
+}
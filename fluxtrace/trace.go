@@ -0,0 +1,337 @@
+// Package fluxtrace implements W3C trace context propagation over message metadata
+// and lightweight spans, which can be exported without any collector.
+package fluxtrace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// MetadataTraceParent is a metadata key of W3C traceparent header.
+const MetadataTraceParent = "traceparent"
+
+const (
+	traceParentVersion = "00"
+	flagSampled        = 0x01
+)
+
+var ErrInvalidTraceParent = errors.New("fluxtrace: invalid traceparent")
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+func (id SpanID) IsValid() bool  { return id != SpanID{} }
+
+// SpanContext is a part of span, which is propagated between services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// TraceParent returns span context in W3C traceparent format.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", traceParentVersion, sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent parses span context from W3C traceparent format.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceParent, value)
+	}
+
+	if parts[0] == traceParentVersion && len(parts) != 4 {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceParent, value)
+	}
+
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return sc, err
+	}
+
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return sc, err
+	}
+
+	var flags [1]byte
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return sc, err
+	}
+
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceParent, value)
+	}
+
+	return sc, nil
+}
+
+func decodeHex(dst []byte, value string) error {
+	if len(value) != hex.EncodedLen(len(dst)) {
+		return fmt.Errorf("%w: bad field length %q", ErrInvalidTraceParent, value)
+	}
+
+	if _, err := hex.Decode(dst, []byte(value)); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTraceParent, err)
+	}
+
+	return nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns context, which carries span context.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns span context, carried by context.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Inject writes span context of ctx into message metadata.
+func Inject(ctx context.Context, msg *message.Message) {
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	msg.Metadata.Set(MetadataTraceParent, sc.TraceParent())
+}
+
+// Extract returns context with span context read from message metadata.
+// When message has no valid traceparent, ctx is returned as is.
+func Extract(ctx context.Context, msg *message.Message) context.Context {
+	sc, err := ParseTraceParent(msg.Metadata.Get(MetadataTraceParent))
+	if err != nil {
+		return ctx
+	}
+
+	return ContextWithSpanContext(ctx, sc)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+var (
+	defaultTracer     *Tracer
+	defaultTracerOnce sync.Once
+	defaultTracerMu   sync.RWMutex
+)
+
+// Default returns process-wide tracer.
+//
+// When FLUX_TRACE_FILE env is set, the default tracer exports spans into this file
+// as JSON lines, otherwise spans are only propagated.
+func Default() *Tracer {
+	defaultTracerOnce.Do(func() {
+		tracer := NewTracer(nil)
+
+		if path := os.Getenv("FLUX_TRACE_FILE"); path != "" {
+			exporter, err := OpenJSONLinesExporter(path)
+			if err == nil {
+				tracer = NewTracer(exporter)
+			}
+		}
+
+		defaultTracerMu.Lock()
+		if defaultTracer == nil {
+			defaultTracer = tracer
+		}
+		defaultTracerMu.Unlock()
+	})
+
+	defaultTracerMu.RLock()
+	defer defaultTracerMu.RUnlock()
+
+	return defaultTracer
+}
+
+// SetDefault replaces process-wide tracer.
+func SetDefault(tracer *Tracer) {
+	defaultTracerMu.Lock()
+	defer defaultTracerMu.Unlock()
+
+	defaultTracer = tracer
+}
+
+// SpanKind describes relationship between the span, its parents, and its children.
+type SpanKind string
+
+const (
+	SpanKindInternal SpanKind = "internal"
+	SpanKindProducer SpanKind = "producer"
+	SpanKindConsumer SpanKind = "consumer"
+	SpanKindClient   SpanKind = "client"
+)
+
+// Tracer creates spans and passes finished ones to the exporter.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer creates tracer. When exporter is nil, spans are only propagated.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start starts a new span, which is a child of the span carried by ctx.
+// It returns context, which carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	sc := SpanContext{
+		TraceID: newTraceID(),
+		SpanID:  newSpanID(),
+		Flags:   flagSampled,
+	}
+
+	var parent SpanID
+
+	if parentContext, ok := SpanContextFromContext(ctx); ok {
+		sc.TraceID = parentContext.TraceID
+		sc.Flags = parentContext.Flags
+		parent = parentContext.SpanID
+	}
+
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		context:    sc,
+		parent:     parent,
+		start:      time.Now(),
+		attributes: make(map[string]string),
+		err:        nil,
+		mu:         sync.Mutex{},
+		ended:      false,
+	}
+
+	return ContextWithSpanContext(ctx, sc), span
+}
+
+// Close closes exporter of the tracer.
+func (t *Tracer) Close() error {
+	if t == nil || t.exporter == nil {
+		return nil
+	}
+
+	if err := t.exporter.Close(); err != nil {
+		return fmt.Errorf("fluxtrace: failed to close exporter: %w", err)
+	}
+
+	return nil
+}
+
+func (t *Tracer) export(data SpanData) {
+	if t == nil || t.exporter == nil || data.Flags&flagSampled == 0 {
+		return
+	}
+
+	_ = t.exporter.ExportSpan(data)
+}
+
+// Span is a single traced operation.
+type Span struct {
+	tracer     *Tracer
+	name       string
+	kind       SpanKind
+	context    SpanContext
+	parent     SpanID
+	start      time.Time
+	attributes map[string]string
+	err        error
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// Context returns span context of the span.
+func (s *Span) Context() SpanContext {
+	return s.context
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attributes[key] = value
+}
+
+// SetError marks span as failed, nil error is ignored.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// End finishes the span and exports it, consequent calls are ignored.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+
+	s.ended = true
+
+	end := time.Now()
+	data := SpanData{
+		Name:         s.name,
+		Kind:         s.kind,
+		TraceID:      s.context.TraceID.String(),
+		SpanID:       s.context.SpanID.String(),
+		ParentSpanID: "",
+		Flags:        s.context.Flags,
+		Start:        s.start,
+		End:          end,
+		Duration:     end.Sub(s.start),
+		Attributes:   s.attributes,
+		Error:        "",
+	}
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	if s.err != nil {
+		data.Error = s.err.Error()
+	}
+	s.mu.Unlock()
+
+	s.tracer.export(data)
+}